@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // Windows 等缺少系统时区数据库的环境下支持 --cron-tz / CRON_TZ=
 
 	"clean_codex_token/internal/app"
 )
 
 func main() {
-	code := app.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := app.RunContext(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
 	os.Exit(code)
 }
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/inventory"
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/probe"
+	"clean_codex_token/internal/report"
+)
+
+// runList 输出各目标中符合筛选条件的账号清单，不做探测
+func runList(ctx context.Context, targets []*target, opts *model.Options, out io.Writer) error {
+	format, err := inventory.ParseFormat(opts.ListFormat)
+	if err != nil {
+		return err
+	}
+	var rows []inventory.Row
+	for _, t := range targets {
+		files, err := t.client.FetchAuthFiles(ctx)
+		if err != nil {
+			return withTarget(t, err)
+		}
+		for _, f := range files {
+			if t.probeSvc.Match(t.opts, f) {
+				rows = append(rows, inventory.Row{Target: t.name, File: f})
+			}
+		}
+	}
+	inventory.Sort(rows)
+
+	if opts.ListGroupBy != "" {
+		cols := inventory.ParseColumns(opts.ListGroupBy)
+		return inventory.WriteGroups(out, format, cols, inventory.GroupBy(rows, cols))
+	}
+	spec := opts.ListColumns
+	if spec == "" && len(targets) > 1 {
+		spec = "target," + inventory.DefaultColumns
+	}
+	if err := inventory.Write(out, format, inventory.ParseColumns(spec), rows); err != nil || format != inventory.FormatTable {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "共 %d 个账号\n", len(rows))
+	return err
+}
+
+// inspection inspect 输出的单个账号
+type inspection struct {
+	Target string         `json:"target,omitempty"`
+	File   model.AuthFile `json:"file"`
+	// History 探测历史，从未探测过时为 null
+	History *history.Record `json:"history"`
+	// Probe 指定 --probe 时的即时探测结果
+	Probe *model.ProbeResult `json:"probe,omitempty"`
+}
+
+// runInspect 按 name、account 或 auth_index 查找账号并输出详情；任一参数未找到时返回错误
+func runInspect(ctx context.Context, targets []*target, keys []string, out io.Writer) error {
+	items, missing, err := findAccounts(ctx, targets, keys, targets[0].opts.InspectProbe)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(items); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("未找到账号: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// findAccounts 在各目标中查找匹配 keys 的账号及其探测历史，doProbe 为 true 时即时探测；
+// missing 为未匹配到任何账号的 key
+func findAccounts(ctx context.Context, targets []*target, keys []string, doProbe bool) (items []inspection, missing []string, err error) {
+	found := make(map[string]bool, len(keys))
+	items = []inspection{}
+	for _, t := range targets {
+		files, err := t.client.FetchAuthFiles(ctx)
+		if err != nil {
+			return nil, nil, withTarget(t, err)
+		}
+		for _, f := range files {
+			id := probe.Identity(f)
+			matched := false
+			for _, k := range keys {
+				if strings.EqualFold(k, id.Name) || (id.Account != "" && strings.EqualFold(k, id.Account)) || (id.AuthIndex != "" && k == id.AuthIndex) {
+					found[k] = true
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+			item := inspection{Target: t.name, File: f}
+			if rec, ok := t.probeSvc.History.Get(history.Key(id.Name, id.AuthIndex)); ok {
+				item.History = &rec
+			}
+			if doProbe {
+				r := t.probeSvc.ProbeFile(ctx, t.opts, f)
+				item.Probe = &r
+			}
+			items = append(items, item)
+		}
+	}
+	for _, k := range keys {
+		if !found[k] {
+			missing = append(missing, k)
+		}
+	}
+	return items, missing, nil
+}
+
+// configTarget config 子命令输出的单个目标
+type configTarget struct {
+	Name    string         `json:"name,omitempty"`
+	Options report.Options `json:"options"`
+}
+
+// configDoc config 子命令与控制接口输出的配置
+type configDoc struct {
+	Config  string         `json:"config"`
+	Targets []configTarget `json:"targets"`
+}
+
+func newConfigDoc(opts *model.Options, targets []*target) configDoc {
+	doc := configDoc{Config: opts.ConfigPath}
+	for _, t := range targets {
+		doc.Targets = append(doc.Targets, configTarget{Name: t.name, Options: report.NewOptions(t.opts)})
+	}
+	return doc
+}
+
+// runConfig 输出合并后的最终配置；到这里时全部配置项均已通过校验
+func runConfig(opts *model.Options, targets []*target, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(newConfigDoc(opts, targets))
+}
+
+func withTarget(t *target, err error) error {
+	if t.name == "" {
+		return err
+	}
+	return fmt.Errorf("[%s] %w", t.name, err)
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"clean_codex_token/internal/control"
+	"clean_codex_token/internal/cron"
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/report"
+)
+
+// daemon cron 模式的运行状态，实现 control.Daemon 供 --listen 控制接口使用
+type daemon struct {
+	opts     *model.Options
+	schedule *cron.Schedule
+	targets  []*target
+	logger   *slog.Logger
+	// trigger 控制接口请求立即执行；只有先占用 running 成功的请求才写入，缓冲为 1 不会阻塞
+	trigger chan struct{}
+	// running 正在执行或已受理控制接口的执行请求
+	running atomic.Bool
+	// paused 暂停删除：每轮只探测、导出，不处置账号
+	paused atomic.Bool
+
+	mu      sync.Mutex
+	nextRun time.Time
+	lastRun time.Time
+	lastErr error
+}
+
+func newDaemon(opts *model.Options, schedule *cron.Schedule, targets []*target, logger *slog.Logger) *daemon {
+	return &daemon{opts: opts, schedule: schedule, targets: targets, logger: logger, trigger: make(chan struct{}, 1)}
+}
+
+func (d *daemon) Status() control.Status {
+	s := control.Status{Cron: d.schedule.Expr, Running: d.running.Load(), DeletePaused: d.paused.Load()}
+	for _, t := range d.targets {
+		if t.name != "" {
+			s.Targets = append(s.Targets, t.name)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.nextRun.IsZero() {
+		next := d.nextRun
+		s.NextRun = &next
+	}
+	if !d.lastRun.IsZero() {
+		last := d.lastRun
+		s.LastRun = &last
+	}
+	if d.lastErr != nil {
+		s.LastError = d.lastErr.Error()
+	}
+	return s
+}
+
+// Trigger 先占用执行状态再请求执行：正在执行或已有待执行的请求时返回 false，
+// 不会在当前一轮结束后紧接着再执行一轮
+func (d *daemon) Trigger() bool {
+	if !d.running.CompareAndSwap(false, true) {
+		return false
+	}
+	d.trigger <- struct{}{}
+	d.logger.Info("控制接口: 已请求立即执行")
+	return true
+}
+
+// claimTick 定时触发时占用执行状态；控制接口同时请求了执行时合并为同一轮，取走该请求
+func (d *daemon) claimTick() {
+	if !d.running.CompareAndSwap(false, true) {
+		<-d.trigger
+	}
+}
+
+func (d *daemon) PauseDeletion(paused bool) {
+	if d.paused.Swap(paused) == paused {
+		return
+	}
+	if paused {
+		d.logger.Warn("控制接口: 已暂停删除，之后每轮只探测不处置", "delete_paused", true)
+	} else {
+		d.logger.Info("控制接口: 已恢复删除", "delete_paused", false)
+	}
+}
+
+func (d *daemon) Reports() []*report.Report {
+	var reps []*report.Report
+	for _, t := range d.targets {
+		if rep := t.rp.lastReport(); rep != nil {
+			reps = append(reps, rep)
+		}
+	}
+	return reps
+}
+
+func (d *daemon) Account(ctx context.Context, key string, probe bool) (any, error) {
+	items, _, err := findAccounts(ctx, d.targets, []string{key}, probe)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: %s", control.ErrNotFound, key)
+	}
+	return items, nil
+}
+
+func (d *daemon) Config() any {
+	return newConfigDoc(d.opts, d.targets)
+}
+
+func (d *daemon) setNext(next time.Time) {
+	d.mu.Lock()
+	d.nextRun = next
+	d.mu.Unlock()
+}
+
+func (d *daemon) finishRun(started time.Time, err error) {
+	d.mu.Lock()
+	d.lastRun, d.lastErr = started, err
+	d.mu.Unlock()
+	d.running.Store(false)
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/logging"
+)
+
+func TestDaemonTriggerRacingTick(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		d := newDaemon(nil, nil, nil, logging.Discard())
+		var accepted bool
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			accepted = d.Trigger()
+		}()
+		// 定时触发与控制接口请求同时到达
+		d.claimTick()
+		wg.Wait()
+		if accepted && len(d.trigger) != 0 {
+			t.Fatalf("iteration %d: accepted trigger must be merged into the running tick, not queued", i)
+		}
+		if !d.running.Load() {
+			t.Fatalf("iteration %d: tick should hold the running slot", i)
+		}
+		// 执行期间的请求返回 false（409），不排队
+		if d.Trigger() {
+			t.Fatalf("iteration %d: trigger during a run must be refused", i)
+		}
+		d.finishRun(time.Now(), nil)
+		if !d.Trigger() || len(d.trigger) != 1 {
+			t.Fatalf("iteration %d: trigger after the run should be accepted once", i)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"clean_codex_token/internal/guard"
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/notify"
+	"clean_codex_token/internal/report"
+	"clean_codex_token/internal/shutdown"
+)
+
+// reporter 每轮结束后导出 --report 并发送通知
+type reporter struct {
+	path     string
+	notifier *notify.Notifier
+	target   string
+	// collect 为 true 时即使不导出也生成报告（多目标汇总、daemon 控制接口需要）
+	collect bool
+	mu      sync.Mutex
+	// last 最近一轮的报告
+	last *report.Report
+}
+
+// lastReport 最近一轮的报告，尚未执行过时为 nil
+func (rp *reporter) lastReport() *report.Report {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.last
+}
+
+// start 既不导出报告、不通知也不需要汇总时返回 nil（report 的方法对 nil 为空操作）
+func (rp *reporter) start(opts *model.Options, mode string, started time.Time) *report.Report {
+	if rp.path == "" && rp.notifier == nil && !rp.collect {
+		return nil
+	}
+	rep := report.New(opts, mode, started)
+	rep.Target = rp.target
+	return rep
+}
+
+func (rp *reporter) finish(ctx context.Context, rep *report.Report, err error, logger *slog.Logger) {
+	if rep == nil {
+		return
+	}
+	if errors.Is(err, shutdown.ErrInterrupted) {
+		err = nil
+	}
+	rep.Finish(time.Now(), shutdown.Interrupted(ctx), err)
+	var tripped *guard.Tripped
+	if errors.As(err, &tripped) {
+		rep.Refused = tripped.Rule
+	}
+	rp.mu.Lock()
+	rp.last = rep
+	rp.mu.Unlock()
+	if rp.path != "" {
+		if werr := rep.Write(rp.path); werr != nil {
+			logger.Error(fmt.Sprintf("导出运行报告失败: %v", werr), "report", rp.path, "error", werr)
+		} else {
+			logger.Info(fmt.Sprintf("已导出运行报告: %s", rp.path), "report", rp.path)
+		}
+	}
+	// 中断排空期间 ctx 可能已取消，通知使用独立的超时
+	nctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+	defer cancel()
+	if nerr := rp.notifier.Notify(nctx, rep); nerr != nil {
+		logger.Warn(fmt.Sprintf("发送通知失败: %v", nerr), "error", nerr)
+	}
+}
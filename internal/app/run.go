@@ -2,29 +2,62 @@ package app
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"strconv"
+	"log/slog"
 	"strings"
 	"time"
 
+	"clean_codex_token/internal/backup"
 	"clean_codex_token/internal/cli"
 	"clean_codex_token/internal/config"
-	"clean_codex_token/internal/deleter"
+	"clean_codex_token/internal/control"
+	"clean_codex_token/internal/cron"
+	"clean_codex_token/internal/guard"
 	"clean_codex_token/internal/har"
-	"clean_codex_token/internal/mgmt"
+	"clean_codex_token/internal/logging"
+	"clean_codex_token/internal/metrics"
 	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/notify"
 	"clean_codex_token/internal/output"
 	"clean_codex_token/internal/probe"
+	"clean_codex_token/internal/shutdown"
+)
+
+// 进程退出码
+const (
+	ExitOK     = 0
+	ExitError  = 1
+	ExitDryRun = 3 // dry-run 正常完成，未执行任何删除
+	// ExitInterrupted 收到 SIGINT/SIGTERM，任务未全部完成（已完成部分已导出）
+	ExitInterrupted = 130
 )
 
 func Run(args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
-	opts := cli.ParseFlags(args)
+	return RunContext(context.Background(), args, in, out, errOut)
+}
+
+// RunContext stop 结束（如收到退出信号）后不再派发新的探测/删除任务，
+// 在途请求最多再等待 --drain-timeout，随后导出已完成的部分结果并返回 ExitInterrupted。
+func RunContext(stop context.Context, args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
+	cmd, err := cli.Parse(args, out)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
+	opts := cmd.Opts
+	// query 为只读查询类子命令：日志写到 errOut，避免混入 out 中的查询结果
+	query := cmd.Name == cli.CmdList || cmd.Name == cli.CmdInspect || cmd.Name == cli.CmdConfig
 
 	conf, err := config.LoadConfigJSON(opts.ConfigPath)
 	if err != nil {
 		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-		return 1
+		return ExitError
 	}
 
 	var harCtx *model.HarContext
@@ -32,246 +65,443 @@ func Run(args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
 		ctx, e := har.LoadContextFromHAR(opts.HarPath)
 		if e != nil {
 			_, _ = fmt.Fprintf(errOut, "错误: 解析 HAR 失败: %v\n", e)
-			return 1
+			return ExitError
 		}
 		harCtx = ctx
 	}
 
 	cli.MergeOptions(opts, conf, harCtx)
-	if opts.Token == "" {
-		if opts.Cron != "" {
+	specs, err := parseTargets(conf["targets"], opts.TargetNames)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
+	// 子命令只有 daemon 按 cron 定时执行；旧版参数形式沿用配置文件中的 cron
+	daemon := opts.Cron != "" && (cmd.Name == "" || cmd.Name == cli.CmdDaemon)
+	if cmd.Name == cli.CmdDaemon && !daemon {
+		_, _ = fmt.Fprintln(errOut, "错误: daemon 需要 --cron 或配置文件中的 cron")
+		return ExitError
+	}
+	// 多目标模式下 token 来自各目标配置，全局 token 仅作为缺省值；config 只输出配置，不需要 token
+	if opts.Token == "" && len(specs) == 0 && cmd.Name != cli.CmdConfig {
+		if daemon {
 			_, _ = fmt.Fprintln(errOut, "错误: cron 无人值守模式下缺少管理 token。请提供 --har（从抓包提取）或 --token/MGMT_TOKEN。")
-			return 1
+			return ExitError
+		}
+		// 子命令面向脚本，不做交互式提示
+		if cmd.Name == "" {
+			opts.Token = cli.PromptToken(in, out)
+		}
+		if opts.Token == "" {
+			_, _ = fmt.Fprintln(errOut, "错误: 缺少管理 token。请提供 --har（从抓包提取）或 --token/MGMT_TOKEN。")
+			return ExitError
 		}
-		opts.Token = cli.PromptToken(in, out)
 	}
-	if opts.Token == "" {
-		_, _ = fmt.Fprintln(errOut, "错误: 缺少管理 token。请提供 --har（从抓包提取）或 --token/MGMT_TOKEN。")
-		return 1
+
+	if opts.OutputFormat, err = output.ParseFormat(opts.OutputFormat); err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: --output-format 不合法: %v\n", err)
+		return ExitError
 	}
 
-	ctx := context.Background()
-	client := mgmt.NewClient(opts.BaseURL, opts.Token, opts.Timeout)
-	probeSvc := probe.NewService(client)
-	deleteSvc := deleter.NewService(client)
-	progress := func(s string) { _, _ = fmt.Fprintln(out, s) }
+	logOut := out
+	if query {
+		logOut = errOut
+	}
+	logger, err := logging.New(logOut, opts.LogFormat, opts.LogLevel)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
 
-	if opts.Cron != "" {
-		schedule, e := parseCron5(opts.Cron)
-		if e != nil {
-			_, _ = fmt.Fprintf(errOut, "错误: cron 表达式不合法: %v\n", e)
-			return 1
+	if err := validateStrategy(opts.Strategy); err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
+	if opts.CanaryMode != probe.CanaryReport && opts.CanaryMode != probe.CanaryAbort {
+		_, _ = fmt.Fprintf(errOut, "错误: 未知 --canary-mode %q（可选 report / abort）\n", opts.CanaryMode)
+		return ExitError
+	}
+
+	opts.Policy = model.DefaultPolicy()
+	if m, ok := conf["policy"].(map[string]any); ok {
+		for k, v := range m {
+			a, _ := v.(string)
+			if err := opts.Policy.Set(k, a); err != nil {
+				_, _ = fmt.Fprintf(errOut, "错误: policy 配置错误: %v\n", err)
+				return ExitError
+			}
 		}
-		opts.Delete = true
-		opts.Yes = true
-		_, _ = fmt.Fprintf(out, "已启用无人值守 cron 模式: %s\n", opts.Cron)
-		_, _ = fmt.Fprintln(out, "模式固定为：检查401并自动删除（跳过确认）")
-		return runCronLoop(ctx, schedule, opts, probeSvc, deleteSvc, out, errOut)
+	}
+	if err := opts.Policy.ParsePolicySpec(opts.PolicySpec); err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: --policy 不合法: %v\n", err)
+		return ExitError
 	}
 
-	if !opts.Delete && !opts.DeleteFromOutput {
-		mode := cli.ChooseModeInteractive(in, out)
-		if mode == "exit" {
-			_, _ = fmt.Fprintln(out, "已退出。")
-			return 0
+	probeDefs, err := probe.ParseDefinitions(conf["probes"])
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
+	notifier, err := notify.Parse(conf["notify"])
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+		return ExitError
+	}
+
+	ctx, cancel := shutdown.WithDrain(stop, opts.DrainTimeout)
+	defer cancel()
+	var recorder *metrics.Recorder
+	if opts.MetricsAddr != "" && !query {
+		recorder = metrics.NewRecorder()
+		addr, err := metrics.Serve(ctx, opts.MetricsAddr, recorder)
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "错误: 启动指标监听失败: %v\n", err)
+			return ExitError
 		}
-		opts.Workers = cli.PromptInt(in, out, "请输入检测并发 workers", opts.Workers, 1)
-		opts.DeleteWorkers = cli.PromptInt(in, out, "请输入删除并发 delete-workers", opts.DeleteWorkers, 1)
-		opts.Timeout = cli.PromptInt(in, out, "请输入请求超时 timeout(秒)", opts.Timeout, 1)
-		opts.Retries = cli.PromptInt(in, out, "请输入失败重试 retries", opts.Retries, 0)
+		logger.Info(fmt.Sprintf("Prometheus 指标: http://%s/metrics", addr), "metrics_addr", addr.String())
+	}
 
-		switch mode {
-		case "check":
-			if _, err := probeSvc.Run(ctx, opts, progress); err != nil {
-				_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-				return 1
+	var targets []*target
+	if len(specs) == 0 {
+		t, err := newTarget("", opts, probeDefs, notifier, recorder, logger)
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+			return ExitError
+		}
+		targets = append(targets, t)
+	} else {
+		for _, spec := range specs {
+			o, err := targetOptions(opts, spec)
+			if err == nil {
+				err = validateStrategy(o.Strategy)
 			}
-			return 0
-		case "check_delete":
-			if err := runCheckDeleteOnce(ctx, opts, probeSvc, deleteSvc, in, out, progress); err != nil {
-				_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-				return 1
+			var t *target
+			if err == nil {
+				t, err = newTarget(spec.Name, o, probeDefs, notifier, recorder, logger)
 			}
-			return 0
-		case "delete_from_output":
-			names, err := output.LoadNamesFromOutput(opts.Output)
 			if err != nil {
 				_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-				return 1
+				return ExitError
 			}
-			_ = deleteSvc.Run(ctx, names, opts.DeleteWorkers, !opts.Yes, in, out, progress)
-			return 0
+			targets = append(targets, t)
+		}
+		logger.Info(fmt.Sprintf("多目标模式: %d 个管理服务", len(targets)), "targets", len(targets))
+	}
+	fleet := len(targets) > 1
+
+	switch cmd.Name {
+	case cli.CmdConfig:
+		if err := runConfig(opts, targets, out); err != nil {
+			return failure(errOut, err)
+		}
+		return ExitOK
+	case cli.CmdList:
+		if err := runList(ctx, targets, opts, out); err != nil {
+			return failure(errOut, err)
+		}
+		return ExitOK
+	case cli.CmdInspect:
+		if err := runInspect(ctx, targets, cmd.Args, out); err != nil {
+			return failure(errOut, err)
 		}
+		return ExitOK
 	}
 
-	if opts.DeleteFromOutput {
-		names, err := output.LoadNamesFromOutput(opts.Output)
+	if opts.RestoreDir != "" {
+		if fleet {
+			_, _ = fmt.Fprintln(errOut, "错误: 多目标模式下恢复备份需要用 --targets 指定单个目标")
+			return ExitError
+		}
+		t := targets[0]
+		results, err := backup.Restore(ctx, t.client, opts.RestoreDir, strings.Split(opts.RestoreNames, ","), opts.RestoreForce, t.logger)
 		if err != nil {
 			_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-			return 1
+			return ExitError
+		}
+		for _, r := range results {
+			if !r.Restored {
+				return ExitError
+			}
 		}
-		_ = deleteSvc.Run(ctx, names, opts.DeleteWorkers, !opts.Yes, in, out, progress)
-		return 0
+		return ExitOK
 	}
 
-	invalid, err := probeSvc.Run(ctx, opts, progress)
-	if err != nil {
-		_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
-		return 1
-	}
-	if opts.Delete {
-		names := make([]string, 0, len(invalid))
-		for _, r := range invalid {
-			if r.Name != "" {
-				names = append(names, r.Name)
+	if daemon {
+		loc := time.Local
+		if opts.CronTZ != "" {
+			l, e := time.LoadLocation(opts.CronTZ)
+			if e != nil {
+				_, _ = fmt.Fprintf(errOut, "错误: --cron-tz 不合法: %v\n", e)
+				return ExitError
 			}
+			loc = l
 		}
-		_ = deleteSvc.Run(ctx, names, opts.DeleteWorkers, !opts.Yes, in, out, progress)
-	} else {
-		_, _ = fmt.Fprintln(out, "当前为仅检查模式。")
+		schedule, e := cron.Parse(opts.Cron, loc)
+		if e != nil {
+			_, _ = fmt.Fprintf(errOut, "错误: cron 表达式不合法: %v\n", e)
+			return ExitError
+		}
+		for _, t := range targets {
+			t.opts.Delete = true
+			t.opts.Yes = true
+		}
+		logger.Info(fmt.Sprintf("已启用无人值守 cron 模式: %s（时区 %s）", opts.Cron, schedule.Location), "cron", opts.Cron, "tz", schedule.Location.String())
+		if opts.DryRun {
+			logger.Info("模式固定为：检查401并演练删除（dry-run，不会真正删除）")
+		} else {
+			logger.Info("模式固定为：检查401并自动删除（跳过确认）")
+			for _, t := range targets {
+				if t.guard == nil {
+					t.logger.Warn("未配置任何删除安全阈值（--max-delete-ratio / --max-delete / --min-keep / --canaries）：上游故障时一轮即可删空账号池，建议至少设置 --max-delete-ratio")
+				}
+			}
+		}
+		d := newDaemon(opts, schedule, targets, logger)
+		if opts.Listen != "" {
+			if opts.APIToken == "" {
+				_, _ = fmt.Fprintln(errOut, "错误: --listen 需要 --api-token（或 CLEAN_CODEX_API_TOKEN / 配置 api_token）")
+				return ExitError
+			}
+			for _, t := range targets {
+				// 控制接口需要最近一轮的报告
+				t.rp.collect = true
+			}
+			addr, err := control.Serve(ctx, opts.Listen, opts.APIToken, d)
+			if err != nil {
+				_, _ = fmt.Fprintf(errOut, "错误: 启动控制接口失败: %v\n", err)
+				return ExitError
+			}
+			logger.Info(fmt.Sprintf("控制接口: http://%s/api/v1/", addr), "listen", addr.String())
+		}
+		return runCronLoop(ctx, d, logger)
 	}
-	return 0
-}
 
-func runCheckDeleteOnce(ctx context.Context, opts *model.Options, probeSvc *probe.Service, deleteSvc *deleter.Service, in io.Reader, out io.Writer, progress func(string)) error {
-	invalid, err := probeSvc.Run(ctx, opts, progress)
-	if err != nil {
-		return err
+	if opts.Listen != "" {
+		logger.Warn("--listen 仅在 daemon（cron）模式下生效，已忽略", "listen", opts.Listen)
 	}
-	names := make([]string, 0, len(invalid))
-	for _, r := range invalid {
-		if r.Name != "" {
-			names = append(names, r.Name)
+
+	mode := "check"
+	switch {
+	case opts.DeleteFromOutput:
+		mode = "delete_from_output"
+	case opts.Delete:
+		mode = "check_delete"
+	case cmd.Name == cli.CmdCheck:
+		mode = "check"
+	default:
+		mode = cli.ChooseModeInteractive(in, out)
+		if mode == "exit" {
+			_, _ = fmt.Fprintln(out, "已退出。")
+			return ExitOK
+		}
+		opts.Workers = cli.PromptInt(in, out, "请输入检测并发 workers", opts.Workers, 1)
+		opts.DeleteWorkers = cli.PromptInt(in, out, "请输入删除并发 delete-workers", opts.DeleteWorkers, 1)
+		opts.Timeout = cli.PromptInt(in, out, "请输入请求超时 timeout(秒)", opts.Timeout, 1)
+		opts.Retries = cli.PromptInt(in, out, "请输入失败重试 retries", opts.Retries, 0)
+		for _, t := range targets {
+			t.opts.Workers, t.opts.DeleteWorkers, t.opts.Timeout, t.opts.Retries = opts.Workers, opts.DeleteWorkers, opts.Timeout, opts.Retries
 		}
 	}
-	_ = deleteSvc.Run(ctx, names, opts.DeleteWorkers, !opts.Yes, in, out, progress)
-	return nil
-}
+	if fleet && mode != "check" && !opts.Yes && !opts.DryRun {
+		// 并发目标无法逐个交互确认
+		_, _ = fmt.Fprintln(errOut, "错误: 多目标模式下删除需要 --yes（或先用 --dry-run 演练）")
+		return ExitError
+	}
 
-func runCronLoop(ctx context.Context, schedule cronSchedule, opts *model.Options, probeSvc *probe.Service, deleteSvc *deleter.Service, out io.Writer, errOut io.Writer) int {
-	lastKey := ""
-	for {
-		now := time.Now()
-		if schedule.match(now) {
-			key := now.Format("2006-01-02 15:04")
-			if key != lastKey {
-				lastKey = key
-				_, _ = fmt.Fprintf(out, "[%s] 开始执行: 401检测+自动删除\n", key)
-				err := runCheckDeleteOnce(ctx, opts, probeSvc, deleteSvc, strings.NewReader(""), out, func(s string) {
-					_, _ = fmt.Fprintln(out, s)
-				})
-				if err != nil {
-					_, _ = fmt.Fprintf(errOut, "[%s] 执行失败: %v\n", key, err)
-				} else {
-					_, _ = fmt.Fprintf(out, "[%s] 执行完成\n", key)
-				}
-			}
+	switch mode {
+	case "delete_from_output":
+		err := sweep(ctx, targets, logger, func(t *target) error {
+			return runDeleteFromOutput(ctx, t, in, out)
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+			return ExitError
+		}
+		return deleteExitCode(ctx, opts)
+	case "check_delete":
+		err := sweep(ctx, targets, logger, func(t *target) error {
+			return runCheck(ctx, t, in, out, "check_delete", true)
+		})
+		if err != nil {
+			return failure(errOut, err)
 		}
-		time.Sleep(1 * time.Second)
+		return deleteExitCode(ctx, opts)
+	default:
+		err := sweep(ctx, targets, logger, func(t *target) error {
+			return runCheck(ctx, t, in, out, "check", false)
+		})
+		if err != nil {
+			return failure(errOut, err)
+		}
+		return ExitOK
 	}
 }
 
-type cronSchedule struct {
-	minute fieldMatcher
-	hour   fieldMatcher
-	dom    fieldMatcher
-	month  fieldMatcher
-	dow    fieldMatcher
+func validateStrategy(s string) error {
+	switch s {
+	case model.StrategyDelete, model.StrategyDisable, model.StrategyQuarantine:
+		return nil
+	default:
+		return fmt.Errorf("未知处置策略 %q（可选 delete / disable / quarantine）", s)
+	}
 }
 
-func (c cronSchedule) match(t time.Time) bool {
-	return c.minute.match(t.Minute()) &&
-		c.hour.match(t.Hour()) &&
-		c.dom.match(t.Day()) &&
-		c.month.match(int(t.Month())) &&
-		c.dow.match(int(t.Weekday()))
+// failure 打印错误并返回对应退出码；中断时不视为普通错误
+func failure(errOut io.Writer, err error) int {
+	if errors.Is(err, shutdown.ErrInterrupted) {
+		_, _ = fmt.Fprintf(errOut, "已中断: %v，未执行删除\n", err)
+		return ExitInterrupted
+	}
+	_, _ = fmt.Fprintf(errOut, "错误: %v\n", err)
+	return ExitError
 }
 
-type fieldMatcher struct {
-	any    bool
-	values map[int]struct{}
+func deleteExitCode(ctx context.Context, opts *model.Options) int {
+	if shutdown.Interrupted(ctx) {
+		return ExitInterrupted
+	}
+	if opts.DryRun {
+		return ExitDryRun
+	}
+	return ExitOK
 }
 
-func (f fieldMatcher) match(v int) bool {
-	if f.any {
-		return true
+func toCandidates(invalid []model.ProbeResult) []model.DeleteCandidate {
+	candidates := make([]model.DeleteCandidate, 0, len(invalid))
+	seen := make(map[string]struct{}, len(invalid))
+	for _, r := range invalid {
+		if _, dup := seen[r.Name]; r.Name != "" && !dup {
+			seen[r.Name] = struct{}{}
+			candidates = append(candidates, model.DeleteCandidate{Name: r.Name, Account: r.Account, AuthIndex: r.AuthIndex, Verdict: r.Verdict, Reason: r.Reason, Action: r.Action})
+		}
 	}
-	_, ok := f.values[v]
-	return ok
+	return candidates
 }
 
-func parseCron5(expr string) (cronSchedule, error) {
-	parts := strings.Fields(strings.TrimSpace(expr))
-	if len(parts) != 5 {
-		return cronSchedule{}, fmt.Errorf("需要 5 段表达式，例如 */5 * * * *")
+// runCheck 执行一轮探测，doDelete 为 true 时随后处置失效账号；返回探测阶段的错误、安全阈值拒绝，
+// 以及整批删除被取消（如备份目录不可用）的错误，删除阶段被中断由调用方通过 shutdown.Interrupted 判断。指定 --report 时导出本轮报告。
+func runCheck(ctx context.Context, t *target, in io.Reader, out io.Writer, mode string, doDelete bool) (err error) {
+	started := time.Now()
+	rep := t.rp.start(t.opts, mode, started)
+	defer func() {
+		t.probeSvc.Metrics.ObserveRun(time.Now(), time.Since(started), err == nil && !shutdown.Interrupted(ctx))
+		t.rp.finish(ctx, rep, err, t.probeSvc.Logger)
+	}()
+	res, err := t.probeSvc.Run(ctx, t.opts)
+	if res != nil {
+		rep.SetProbe(res.Total, res.Matched, res.Accounts)
+		if res.Canary != nil && !errors.Is(err, shutdown.ErrInterrupted) && (doDelete || err != nil) {
+			// 金丝雀未通过，本轮结论不可信
+			return t.refuse(&guard.Tripped{Rule: guard.RuleCanary, Reason: res.Canary.Error()}, "canary", res.Canary.Name, "phase", res.Canary.Phase)
+		}
 	}
-	min, err := parseField(parts[0], 0, 59)
-	if err != nil {
-		return cronSchedule{}, fmt.Errorf("minute: %w", err)
+	if err != nil || !doDelete {
+		return err
 	}
-	hour, err := parseField(parts[1], 0, 23)
-	if err != nil {
-		return cronSchedule{}, fmt.Errorf("hour: %w", err)
+	t.deleteSvc.ReleaseRecovered(ctx)
+	candidates := toCandidates(res.Invalid)
+	// 金丝雀已由探测阶段按本轮选中的文件校验，这里也只按这些文件匹配
+	if err := t.checkGuard(t.guard.ForCanaries(res.CanaryKeys), res.Matched, guard.HealthyLeft(res.Accounts, candidates), res.Accounts, candidates); err != nil {
+		return err
 	}
-	dom, err := parseField(parts[2], 1, 31)
+	results, err := t.deleteSvc.Run(ctx, candidates, t.opts.DeleteWorkers, !t.opts.Yes, in, out)
+	rep.AddDeletes(results)
+	return err
+}
+
+func runDeleteFromOutput(ctx context.Context, t *target, in io.Reader, out io.Writer) error {
+	candidates, meta, err := output.LoadCandidatesFromOutput(t.opts.Output)
 	if err != nil {
-		return cronSchedule{}, fmt.Errorf("day-of-month: %w", err)
+		return err
 	}
-	month, err := parseField(parts[3], 1, 12)
-	if err != nil {
-		return cronSchedule{}, fmt.Errorf("month: %w", err)
+	rep := t.rp.start(t.opts, "delete_from_output", time.Now())
+	candidates, known, err := t.checkOutput(ctx, meta, candidates)
+	// 没有本轮探测结果，金丝雀只能检查候选列表；比例与剩余健康账号数按管理服务中当前的账号及其最近一次探测结论评估
+	if err == nil {
+		err = t.checkGuard(t.guard, len(known), guard.HealthyLeft(known, candidates), nil, candidates)
 	}
-	dow, err := parseField(parts[4], 0, 6)
-	if err != nil {
-		return cronSchedule{}, fmt.Errorf("day-of-week: %w", err)
+	if err == nil {
+		var results []model.DeleteResult
+		results, err = t.deleteSvc.Run(ctx, candidates, t.opts.DeleteWorkers, !t.opts.Yes, in, out)
+		rep.AddDeletes(results)
 	}
-	return cronSchedule{minute: min, hour: hour, dom: dom, month: month, dow: dow}, nil
+	t.rp.finish(ctx, rep, err, t.deleteSvc.Logger)
+	return err
 }
 
-func parseField(part string, min int, max int) (fieldMatcher, error) {
-	if part == "*" {
-		return fieldMatcher{any: true}, nil
-	}
-	values := make(map[int]struct{})
-	segments := strings.Split(part, ",")
-	for _, seg := range segments {
-		seg = strings.TrimSpace(seg)
-		if seg == "" {
-			return fieldMatcher{}, fmt.Errorf("空段")
-		}
-		if strings.HasPrefix(seg, "*/") {
-			step, err := strconv.Atoi(strings.TrimPrefix(seg, "*/"))
-			if err != nil || step <= 0 {
-				return fieldMatcher{}, fmt.Errorf("非法步长: %s", seg)
-			}
-			for i := min; i <= max; i += step {
-				values[i] = struct{}{}
-			}
-			continue
+// runCronLoop 按调度计算下一次触发时间并等待，控制接口请求时立即执行；单次执行耗时超过调度间隔时，
+// 执行期间错过的触发点直接跳过，不会重叠执行。多目标时每轮并发处理全部目标。
+func runCronLoop(ctx context.Context, d *daemon, logger *slog.Logger) int {
+	schedule, targets := d.schedule, d.targets
+	next := schedule.Next(time.Now())
+	for {
+		if next.IsZero() {
+			logger.Error("错误: cron 表达式在未来 5 年内没有可执行时间", "cron", schedule.Expr)
+			return ExitError
 		}
-		if strings.Contains(seg, "-") {
-			r := strings.SplitN(seg, "-", 2)
-			if len(r) != 2 {
-				return fieldMatcher{}, fmt.Errorf("非法范围: %s", seg)
-			}
-			start, err1 := strconv.Atoi(r[0])
-			end, err2 := strconv.Atoi(r[1])
-			if err1 != nil || err2 != nil || start > end || start < min || end > max {
-				return fieldMatcher{}, fmt.Errorf("非法范围: %s", seg)
-			}
-			for i := start; i <= end; i++ {
-				values[i] = struct{}{}
-			}
-			continue
+		d.setNext(next)
+		logger.Info(fmt.Sprintf("下次执行时间: %s", next.Format("2006-01-02 15:04 MST")), "next_run", next)
+		timer := time.NewTimer(time.Until(next))
+		manual := false
+		select {
+		case <-shutdown.Stopping(ctx):
+			timer.Stop()
+			logger.Info("收到退出信号，已退出 cron 模式。")
+			return ExitOK
+		case <-timer.C:
+			d.claimTick()
+		case <-d.trigger:
+			timer.Stop()
+			manual = true
 		}
-		n, err := strconv.Atoi(seg)
-		if err != nil || n < min || n > max {
-			return fieldMatcher{}, fmt.Errorf("非法值: %s", seg)
+
+		started := time.Now()
+		key := next.Format("2006-01-02 15:04")
+		if manual {
+			key = started.Format("2006-01-02 15:04:05")
 		}
-		values[n] = struct{}{}
-	}
-	if len(values) == 0 {
-		return fieldMatcher{}, fmt.Errorf("没有可用值")
+		runLog := logger.With("run", key)
+		for _, t := range targets {
+			// 本轮内的探测/删除日志都带上 run 字段，便于日志平台按轮次聚合
+			l := t.logger.With("run", key)
+			t.probeSvc.Logger, t.deleteSvc.Logger = l, l
+		}
+		doDelete := !d.paused.Load()
+		switch {
+		case !doDelete:
+			runLog.Warn(fmt.Sprintf("[%s] 开始执行: 401检测（删除已暂停，只探测不处置）", key), "manual", manual, "delete_paused", true)
+		case manual:
+			runLog.Info(fmt.Sprintf("[%s] 开始执行（控制接口触发）: 401检测+自动删除", key), "manual", true)
+		default:
+			runLog.Info(fmt.Sprintf("[%s] 开始执行: 401检测+自动删除", key))
+		}
+		err := sweep(ctx, targets, runLog, func(t *target) error {
+			return runCheck(ctx, t, strings.NewReader(""), io.Discard, "cron", doDelete)
+		})
+		d.finishRun(started, err)
+		if errors.Is(err, shutdown.ErrInterrupted) || shutdown.Interrupted(ctx) {
+			runLog.Warn(fmt.Sprintf("[%s] 执行被中断，已退出 cron 模式", key))
+			return ExitInterrupted
+		}
+		if err != nil {
+			runLog.Error(fmt.Sprintf("[%s] 执行失败: %v", key, err), "error", err)
+		} else {
+			runLog.Info(fmt.Sprintf("[%s] 执行完成，耗时 %s", key, time.Since(started).Round(time.Second)), "duration_ms", time.Since(started).Milliseconds())
+		}
+
+		now := time.Now()
+		skipped := 0
+		from := schedule.Next(next)
+		if manual {
+			// 手动执行时 next 尚未触发，执行期间到点也算跳过
+			from = next
+		}
+		for n := from; !n.IsZero() && !n.After(now); n = schedule.Next(n) {
+			skipped++
+		}
+		if skipped > 0 {
+			runLog.Warn(fmt.Sprintf("[%s] 执行耗时超过调度间隔，已跳过 %d 次重叠执行", key, skipped), "skipped", skipped)
+		}
+		next = schedule.Next(now)
 	}
-	return fieldMatcher{values: values}, nil
 }
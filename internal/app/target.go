@@ -0,0 +1,411 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"clean_codex_token/internal/deleter"
+	"clean_codex_token/internal/filter"
+	"clean_codex_token/internal/guard"
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/metrics"
+	"clean_codex_token/internal/mgmt"
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/notify"
+	"clean_codex_token/internal/output"
+	"clean_codex_token/internal/probe"
+	"clean_codex_token/internal/ratelimit"
+	"clean_codex_token/internal/shutdown"
+)
+
+// target 一个管理服务及其独立的参数、历史、探测/删除服务；单服务模式下只有一个 name 为空的 target
+type target struct {
+	name      string
+	opts      *model.Options
+	client    *mgmt.Client
+	probeSvc  *probe.Service
+	deleteSvc *deleter.Service
+	rp        *reporter
+	guard     *guard.Guard
+	logger    *slog.Logger
+}
+
+// targetSpec config.json 中 targets 数组的一项；未填写的字段沿用全局参数
+type targetSpec struct {
+	Name             string            `json:"name"`
+	BaseURL          string            `json:"base_url"`
+	Token            string            `json:"token"`
+	CPAPassword      string            `json:"cpa_password"`
+	TargetType       string            `json:"target_type"`
+	Provider         string            `json:"provider"`
+	ChatgptAccountID string            `json:"chatgpt_account_id"`
+	Workers          int               `json:"workers"`
+	DeleteWorkers    int               `json:"delete_workers"`
+	ProbeRPS         float64           `json:"probe_rps"`
+	Strategy         string            `json:"strategy"`
+	Policy           map[string]string `json:"policy"`
+	Output           string            `json:"output"`
+	Report           string            `json:"report"`
+	History          string            `json:"history"`
+	BackupDir        string            `json:"backup_dir"`
+	MaxDeleteRatio   float64           `json:"max_delete_ratio"`
+	MaxDelete        int               `json:"max_delete"`
+	MinKeep          int               `json:"min_keep"`
+	Canaries         []string          `json:"canaries"`
+	Filter           string            `json:"filter"`
+	Allow            []string          `json:"allow"`
+	Protect          []string          `json:"protect"`
+}
+
+// parseTargets 解析 config.json 中的 targets；only 非空时只保留指定名称（逗号分隔）
+func parseTargets(raw any, only string) ([]targetSpec, error) {
+	if raw == nil {
+		if only != "" {
+			return nil, fmt.Errorf("--targets 需要在配置文件中定义 targets")
+		}
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("targets 配置错误: %w", err)
+	}
+	var specs []targetSpec
+	if err := json.Unmarshal(b, &specs); err != nil {
+		return nil, fmt.Errorf("targets 配置错误: %w", err)
+	}
+	seen := make(map[string]struct{}, len(specs))
+	for i, s := range specs {
+		if s.Name == "" || strings.ContainsAny(s.Name, `/\ `) {
+			return nil, fmt.Errorf("targets[%d] name 不能为空且不能包含空格或路径分隔符", i)
+		}
+		if _, dup := seen[s.Name]; dup {
+			return nil, fmt.Errorf("targets[%d] name 重复: %s", i, s.Name)
+		}
+		seen[s.Name] = struct{}{}
+		if s.BaseURL == "" {
+			return nil, fmt.Errorf("targets[%d] 缺少 base_url", i)
+		}
+	}
+	if only == "" {
+		return specs, nil
+	}
+	want := strings.Split(only, ",")
+	selected := make([]targetSpec, 0, len(want))
+	for _, n := range want {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		found := false
+		for _, s := range specs {
+			if s.Name == n {
+				selected = append(selected, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("--targets 中的目标不存在: %s", n)
+		}
+	}
+	return selected, nil
+}
+
+// targetOptions 在全局参数的副本上应用 spec；未单独配置的输出文件按目标名加后缀，避免多个目标互相覆盖
+func targetOptions(base *model.Options, s targetSpec) (*model.Options, error) {
+	o := *base
+	o.Policy = base.Policy.Clone()
+	o.BaseURL = strings.TrimRight(s.BaseURL, "/")
+	switch {
+	case s.Token != "":
+		o.Token = s.Token
+	case s.CPAPassword != "":
+		o.Token = s.CPAPassword
+	}
+	if o.Token == "" {
+		return nil, fmt.Errorf("目标 %s 缺少管理 token", s.Name)
+	}
+	if s.TargetType != "" {
+		o.TargetType = s.TargetType
+	}
+	if s.Provider != "" {
+		o.Provider = s.Provider
+	}
+	if s.ChatgptAccountID != "" {
+		o.ChatgptAccountID = s.ChatgptAccountID
+	}
+	if s.Workers > 0 {
+		o.Workers = s.Workers
+	}
+	if s.DeleteWorkers > 0 {
+		o.DeleteWorkers = s.DeleteWorkers
+	}
+	if s.ProbeRPS > 0 {
+		o.ProbeRPS = s.ProbeRPS
+	}
+	if s.Strategy != "" {
+		o.Strategy = s.Strategy
+	}
+	if s.MaxDeleteRatio > 0 {
+		o.MaxDeleteRatio = s.MaxDeleteRatio
+	}
+	if s.MaxDelete > 0 {
+		o.MaxDelete = s.MaxDelete
+	}
+	if s.MinKeep > 0 {
+		o.MinKeep = s.MinKeep
+	}
+	// 金丝雀、allow / protect 名单属于具体的管理服务，配置后替换全局值而不是合并
+	if len(s.Canaries) > 0 {
+		o.Canaries = strings.Join(s.Canaries, ",")
+	}
+	if len(s.Allow) > 0 {
+		o.Allow = strings.Join(s.Allow, ",")
+	}
+	if len(s.Protect) > 0 {
+		o.Protect = strings.Join(s.Protect, ",")
+	}
+	if s.Filter != "" {
+		o.Filter = s.Filter
+	}
+	for k, v := range s.Policy {
+		if err := o.Policy.Set(k, v); err != nil {
+			return nil, fmt.Errorf("目标 %s policy 配置错误: %w", s.Name, err)
+		}
+	}
+	o.Output = pick(s.Output, withSuffix(base.Output, s.Name))
+	o.HistoryPath = pick(s.History, withSuffix(base.HistoryPath, s.Name))
+	o.PlanOutput = withSuffix(base.PlanOutput, s.Name)
+	o.BackupDir = pick(s.BackupDir, filepath.Join(base.BackupDir, s.Name))
+	if base.ReportPath != "" || s.Report != "" {
+		o.ReportPath = pick(s.Report, withSuffix(base.ReportPath, s.Name))
+	}
+	return &o, nil
+}
+
+// newTarget 为一组参数创建独立的管理客户端、历史与探测/删除服务
+func newTarget(name string, opts *model.Options, defs []probe.Definition, notifier *notify.Notifier, recorder *metrics.Recorder, logger *slog.Logger) (*target, error) {
+	canaries := splitNames(opts.Canaries)
+	g, err := guard.New(opts.MaxDeleteRatio, opts.MaxDelete, opts.MinKeep, canaries)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := filter.Parse(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := filter.ParseNames(opts.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("--allow 不合法: %w", err)
+	}
+	protect, err := filter.ParseNames(opts.Protect)
+	if err != nil {
+		return nil, fmt.Errorf("--protect 不合法: %w", err)
+	}
+	store, err := history.Open(opts.HistoryPath)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		logger = logger.With("target", name)
+		recorder = recorder.WithTarget(name)
+	}
+	client := mgmt.NewClient(opts.BaseURL, opts.Token, opts.Timeout)
+	if recorder != nil {
+		client.OnError = recorder.MgmtError
+	}
+
+	probeSvc := probe.NewService(client, store)
+	probeSvc.Registry = probe.NewRegistry(defs...)
+	probeSvc.Policy = opts.Policy
+	probeSvc.Limiter = ratelimit.New(opts.ProbeRPS, int(opts.ProbeRPS))
+	probeSvc.Metrics = recorder
+	probeSvc.Logger = logger
+	probeSvc.Filter = expr
+	probeSvc.Canaries = canaries
+	probeSvc.CanaryMode = opts.CanaryMode
+	probeSvc.CoolingCycles = opts.CoolingCycles
+
+	deleteSvc := deleter.NewService(client, store)
+	deleteSvc.Strategy = opts.Strategy
+	deleteSvc.MinFailures = opts.QuarantineFails
+	deleteSvc.Window = opts.QuarantineWindow
+	deleteSvc.Policy = opts.Policy
+	deleteSvc.DryRun = opts.DryRun
+	deleteSvc.PlanOutput = opts.PlanOutput
+	deleteSvc.Metrics = recorder
+	deleteSvc.Logger = logger
+	deleteSvc.Allow = allow
+	deleteSvc.Protect = protect
+	if !opts.NoBackup {
+		deleteSvc.BackupDir = opts.BackupDir
+	}
+	if opts.Reverify {
+		deleteSvc.Reverify = func(ctx context.Context, c model.DeleteCandidate) (model.ProbeResult, error) {
+			return probeSvc.Reverify(ctx, opts, c)
+		}
+	}
+
+	return &target{
+		name:      name,
+		opts:      opts,
+		client:    client,
+		probeSvc:  probeSvc,
+		deleteSvc: deleteSvc,
+		rp:        &reporter{path: opts.ReportPath, notifier: notifier, target: name, collect: name != ""},
+		guard:     g,
+		logger:    logger,
+	}, nil
+}
+
+// checkOutput 校验 --delete-from-output 使用的文件：过期或来自其他管理服务时拒绝；
+// 筛选条件不一致、缺少生成信息时警告；已不在管理服务中的账号剔除。
+// 同时返回管理服务中当前匹配筛选条件或待处置的账号，结论取探测历史中的最近一次（没有历史时为 unknown），
+// 供安全阈值评估比例与剩余健康账号数
+func (t *target) checkOutput(ctx context.Context, meta *output.Meta, candidates []model.DeleteCandidate) ([]model.DeleteCandidate, []model.ProbeResult, error) {
+	log := t.deleteSvc.Logger
+	if meta == nil {
+		log.Warn(fmt.Sprintf("%s 缺少生成信息（旧版或手写文件），无法校验生成时间与管理服务", t.opts.Output), "output", t.opts.Output)
+	} else {
+		if err := meta.Check(t.opts.BaseURL, t.opts.MaxOutputAge, time.Now()); err != nil {
+			return nil, nil, err
+		}
+		if !strings.EqualFold(meta.TargetType, t.opts.TargetType) || !strings.EqualFold(meta.Provider, t.opts.Provider) || meta.Filter != t.opts.Filter {
+			log.Warn(fmt.Sprintf("output 生成时的筛选条件为 target-type=%s provider=%s filter=%q，与当前参数不一致", meta.TargetType, meta.Provider, meta.Filter),
+				"output_target_type", meta.TargetType, "output_provider", meta.Provider, "output_filter", meta.Filter)
+		}
+		log.Info(fmt.Sprintf("output 生成于 %s（%s %s）", meta.GeneratedAt.Local().Format("2006-01-02 15:04:05"), meta.Tool, meta.Version),
+			"generated_at", meta.GeneratedAt, "version", meta.Version)
+	}
+
+	files, err := t.client.FetchAuthFiles(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("校验 output 账号是否存在失败: %w", err)
+	}
+	// exists 记录账号是否匹配当前筛选条件
+	exists := make(map[string]bool, len(files))
+	for _, f := range files {
+		if name, _ := f["name"].(string); name != "" {
+			exists[name] = t.probeSvc.Match(t.opts, f)
+		}
+	}
+	kept := make([]model.DeleteCandidate, 0, len(candidates))
+	missing := make([]string, 0)
+	for _, c := range candidates {
+		if _, ok := exists[c.Name]; ok {
+			kept = append(kept, c)
+			exists[c.Name] = true
+		} else {
+			missing = append(missing, c.Name)
+		}
+	}
+	known := make([]model.ProbeResult, 0, len(exists))
+	for _, f := range files {
+		r := probe.Identity(f)
+		if !exists[r.Name] {
+			continue
+		}
+		r.Verdict = model.VerdictUnknown
+		if rec, ok := t.probeSvc.History.Get(history.Key(r.Name, r.AuthIndex)); ok {
+			r.Verdict = rec.LastOutcome
+		}
+		known = append(known, r)
+	}
+	if len(missing) > 0 {
+		log.Warn(fmt.Sprintf("output 中 %d 个账号已不在管理服务中，已跳过: %s", len(missing), strings.Join(missing, ", ")), "missing", missing)
+	}
+	return kept, known, nil
+}
+
+// checkGuard 删除前评估安全阈值 g；触发时记录日志与指标并返回 *guard.Tripped
+func (t *target) checkGuard(g *guard.Guard, matched, healthy int, accounts []model.ProbeResult, candidates []model.DeleteCandidate) error {
+	err := g.Check(matched, healthy, accounts, candidates)
+	var tripped *guard.Tripped
+	if errors.As(err, &tripped) {
+		t.refuse(tripped, "candidates", len(candidates), "matched", matched, "healthy_left", healthy)
+	}
+	return err
+}
+
+// refuse 记录一次拒绝删除
+func (t *target) refuse(tripped *guard.Tripped, attrs ...any) error {
+	t.deleteSvc.Logger.Error(tripped.Error(), append([]any{"rule", tripped.Rule}, attrs...)...)
+	t.deleteSvc.Metrics.GuardTrip(tripped.Rule)
+	return tripped
+}
+
+// sweep 对每个目标执行 fn：单目标时直接执行；多目标时并发执行，单个目标失败不影响其他目标，
+// 结束后输出各目标汇总，返回合并后的错误
+func sweep(ctx context.Context, targets []*target, logger *slog.Logger, fn func(t *target) error) error {
+	if len(targets) == 1 {
+		return fn(targets[0])
+	}
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			if err := fn(t); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", t.name, err)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, t := range targets {
+		status := "完成"
+		if errs[i] != nil {
+			failed++
+			status = "失败: " + errors.Unwrap(errs[i]).Error()
+		} else if shutdown.Interrupted(ctx) {
+			status = "已中断"
+		}
+		rep := t.rp.lastReport()
+		if rep == nil {
+			logger.Info(fmt.Sprintf("[%s] %s", t.name, status), "target", t.name, "status", status)
+			continue
+		}
+		c := rep.Counts
+		logger.Info(fmt.Sprintf("[%s] %s: 匹配=%d，失效=%d，删除成功=%d，删除失败=%d，禁用/隔离=%d",
+			t.name, status, c.Matched, c.Invalid, c.Deleted, c.DeleteFailed, c.Disabled+c.Quarantined),
+			"target", t.name, "status", status, "matched", c.Matched, "invalid", c.Invalid, "deleted", c.Deleted, "delete_failed", c.DeleteFailed)
+	}
+	logger.Info(fmt.Sprintf("多目标执行完成: 目标=%d，失败=%d", len(targets), failed), "targets", len(targets), "failed", failed)
+	return errors.Join(errs...)
+}
+
+// withSuffix 在扩展名前插入目标名，例如 out.json -> out.prod.json
+func withSuffix(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// splitNames 拆分逗号分隔的名称列表，忽略空项
+func splitNames(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func pick(v, fallback string) string {
+	if v != "" {
+		return v
+	}
+	return fallback
+}
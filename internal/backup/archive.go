@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"clean_codex_token/internal/mgmt"
+)
+
+const manifestFile = "manifest.json"
+
+type Entry struct {
+	Name       string    `json:"name"`
+	File       string    `json:"file"`
+	Reason     string    `json:"reason,omitempty"`
+	BackedUpAt time.Time `json:"backed_up_at"`
+}
+
+type Manifest struct {
+	BaseURL   string    `json:"base_url"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// Archive 一次删除运行对应的备份目录：<root>/<时间戳>/files/<name> + manifest.json；
+// 同一秒内已有备份目录时依次加后缀 -2、-3…
+type Archive struct {
+	Dir      string
+	mu       sync.Mutex
+	manifest Manifest
+	// files 已占用的备份文件名
+	files map[string]struct{}
+}
+
+func NewArchive(root, baseURL string, now time.Time) (*Archive, error) {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+	base := filepath.Join(root, now.Format("20060102-150405"))
+	dir := base
+	// os.Mkdir 在目录已存在时失败，同一秒内的多次删除（含多个进程）不会共用目录、互相覆盖 manifest
+	for i := 2; ; i++ {
+		err := os.Mkdir(dir, 0o700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) || i > 1000 {
+			return nil, fmt.Errorf("创建备份目录失败: %w", err)
+		}
+		dir = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "files"), 0o700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+	return &Archive{Dir: dir, manifest: Manifest{BaseURL: baseURL, CreatedAt: now}, files: make(map[string]struct{})}, nil
+}
+
+// Write 写入单个 auth 文件内容并刷新 manifest，返回后即可安全删除。
+// 不同账号名对应同一文件名（如 a/x.json 与 b/x.json）时依次加后缀 x-2.json、x-3.json…，实际文件名记于 Entry.File
+func (a *Archive) Write(name, reason string, content []byte) error {
+	file, err := a.reserve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(a.Dir, "files", file), content, 0o600); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.manifest.Entries = append(a.manifest.Entries, Entry{Name: name, File: file, Reason: reason, BackedUpAt: time.Now()})
+	return a.writeManifest()
+}
+
+// reserve 为 name 选定一个本目录内未占用的备份文件名
+func (a *Archive) reserve(name string) (string, error) {
+	file, err := safeFileName(name)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ext := filepath.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+	for i := 2; ; i++ {
+		if _, used := a.files[file]; !used {
+			a.files[file] = struct{}{}
+			return file, nil
+		}
+		file = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
+
+func (a *Archive) writeManifest() error {
+	b, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(a.Dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("写入备份清单失败: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(a.Dir, manifestFile))
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %w", err)
+	}
+	return &m, nil
+}
+
+type RestoreResult struct {
+	Name       string `json:"name"`
+	Restored   bool   `json:"restored"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error"`
+}
+
+// Restore 将备份目录中的 auth 文件重新上传到管理服务；names 为空时恢复全部，
+// names 中不在备份清单里的账号记为恢复失败。备份来自其他管理服务时拒绝，force 为 true 时只警告
+func Restore(ctx context.Context, client *mgmt.Client, dir string, names []string, force bool, logger *slog.Logger) ([]RestoreResult, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(strings.TrimRight(m.BaseURL, "/"), strings.TrimRight(client.BaseURL, "/")) {
+		if !force {
+			return nil, fmt.Errorf("备份来自其他管理服务（%s），当前为 %s；确认要恢复到当前服务请加 --force（旧版参数为 --restore-force）", m.BaseURL, client.BaseURL)
+		}
+		logger.Warn(fmt.Sprintf("备份来自其他管理服务（%s），已按 --force 恢复到 %s", m.BaseURL, client.BaseURL), "backup_base_url", m.BaseURL, "base_url", client.BaseURL)
+	}
+	want := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			want[n] = struct{}{}
+		}
+	}
+
+	entries := make([]Entry, 0, len(m.Entries))
+	found := make(map[string]bool, len(want))
+	for _, e := range m.Entries {
+		if _, ok := want[e.Name]; len(want) == 0 || ok {
+			entries = append(entries, e)
+			found[e.Name] = true
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	logger.Info(fmt.Sprintf("备份目录: %s（原服务 %s，%d 个账号），待恢复: %d", dir, m.BaseURL, len(m.Entries), len(entries)),
+		"backup_dir", dir, "base_url", m.BaseURL, "entries", len(m.Entries), "pending", len(entries))
+
+	results := make([]RestoreResult, 0, len(entries)+len(want))
+	success := 0
+	missing := make([]string, 0)
+	for n := range want {
+		if !found[n] {
+			missing = append(missing, n)
+		}
+	}
+	sort.Strings(missing)
+	for _, n := range missing {
+		r := RestoreResult{Name: n, Error: "备份清单中没有该账号"}
+		logger.Warn(fmt.Sprintf("[恢复失败] %s | %s", r.Name, r.Error), "name", r.Name, "error", r.Error)
+		results = append(results, r)
+	}
+	for _, e := range entries {
+		r := RestoreResult{Name: e.Name}
+		content, err := os.ReadFile(filepath.Join(dir, "files", e.File))
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			status, data, text, err := client.UploadAuthFile(ctx, e.Name, content)
+			r.StatusCode = status
+			switch {
+			case err != nil:
+				r.Error = err.Error()
+			case status == 200 && data["status"] == "ok":
+				r.Restored = true
+				success++
+			default:
+				if len(text) > 200 {
+					text = text[:200]
+				}
+				r.Error = "upload failed, response=" + text
+			}
+		}
+		if !r.Restored {
+			logger.Warn(fmt.Sprintf("[恢复失败] %s | %s", r.Name, r.Error), "name", r.Name, "status_code", r.StatusCode, "error", r.Error)
+		} else {
+			logger.Debug(fmt.Sprintf("[已恢复] %s", r.Name), "name", r.Name, "status_code", r.StatusCode)
+		}
+		results = append(results, r)
+	}
+	logger.Info(fmt.Sprintf("恢复完成: 成功=%d，失败=%d", success, len(results)-success), "restored", success, "failed", len(results)-success)
+	return results, nil
+}
+
+func safeFileName(name string) (string, error) {
+	file := filepath.Base(filepath.Clean(name))
+	if file == "." || file == ".." || file == string(filepath.Separator) || file == "" {
+		return "", fmt.Errorf("非法文件名: %q", name)
+	}
+	return file, nil
+}
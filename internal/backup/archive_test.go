@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/logging"
+	"clean_codex_token/internal/mgmt"
+)
+
+func TestNewArchiveSameSecondUsesDistinctDirs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	a, err := NewArchive(root, "http://x", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewArchive(root, "http://x", now.Add(300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Dir == b.Dir || filepath.Base(b.Dir) != "20260301-120000-2" {
+		t.Fatalf("archives in the same second must not share a dir: %s %s", a.Dir, b.Dir)
+	}
+	if err := a.Write("a.json", "401", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write("b.json", "401", []byte(`{"b":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	for dir, want := range map[string]string{a.Dir: "a.json", b.Dir: "b.json"} {
+		m, err := LoadManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Entries) != 1 || m.Entries[0].Name != want {
+			t.Fatalf("unexpected manifest in %s: %+v", dir, m.Entries)
+		}
+	}
+}
+
+func TestArchiveKeepsCollidingFileNamesApart(t *testing.T) {
+	a, err := NewArchive(t.TempDir(), "http://x", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{"a/x.json": `{"a":1}`, "b/x.json": `{"b":1}`, "x.json": `{"x":1}`}
+	for _, name := range []string{"a/x.json", "b/x.json", "x.json"} {
+		if err := a.Write(name, "401", []byte(contents[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := LoadManifest(a.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, e := range m.Entries {
+		if seen[e.File] {
+			t.Fatalf("two entries share backup file %s: %+v", e.File, m.Entries)
+		}
+		seen[e.File] = true
+		b, err := os.ReadFile(filepath.Join(a.Dir, "files", e.File))
+		if err != nil || string(b) != contents[e.Name] {
+			t.Fatalf("%s: backup file %s has %q, want %q (err=%v)", e.Name, e.File, b, contents[e.Name], err)
+		}
+	}
+	if m.Entries[1].File != "x-2.json" {
+		t.Fatalf("expected suffixed file name, got %+v", m.Entries)
+	}
+}
+
+func TestRestoreReportsUnknownNames(t *testing.T) {
+	a, err := NewArchive(t.TempDir(), "http://x", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Write("a.json", "401", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	results, err := Restore(context.Background(), mgmt.NewClient("http://x", "t", 5), a.Dir, []string{"typo.json"}, false, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "typo.json" || results[0].Restored || results[0].Error == "" {
+		t.Fatalf("unknown name should be a failed result, got %+v", results)
+	}
+}
+
+func TestRestoreRefusesOtherServer(t *testing.T) {
+	var uploads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+	}))
+	defer srv.Close()
+	a, err := NewArchive(t.TempDir(), "http://prod:8317", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Write("a.json", "401", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	client := mgmt.NewClient(srv.URL, "t", 5)
+	if _, err := Restore(context.Background(), client, a.Dir, nil, false, logging.Discard()); err == nil || !strings.Contains(err.Error(), "http://prod:8317") {
+		t.Fatalf("expected refusal for a backup from another server, got %v", err)
+	}
+	if n := uploads.Load(); n != 0 {
+		t.Fatalf("refused restore must not upload, got %d uploads", n)
+	}
+	results, err := Restore(context.Background(), client, a.Dir, nil, true, logging.Discard())
+	if err != nil || len(results) != 1 || !results[0].Restored {
+		t.Fatalf("--force should restore, got %+v err=%v", results, err)
+	}
+}
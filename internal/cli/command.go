@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"clean_codex_token/internal/inventory"
+	"clean_codex_token/internal/model"
+)
+
+// 子命令名称
+const (
+	CmdCheck   = "check"
+	CmdDelete  = "delete"
+	CmdDaemon  = "daemon"
+	CmdList    = "list"
+	CmdInspect = "inspect"
+	CmdRestore = "restore"
+	CmdConfig  = "config"
+)
+
+const program = "clean-codex-accounts"
+
+// Command 解析后的命令行
+type Command struct {
+	// Name 子命令名称；旧版参数形式（--delete / --cron 等，或无参数进入交互菜单）为空
+	Name string
+	Opts *model.Options
+	// Args 子命令的位置参数
+	Args []string
+}
+
+type commandDef struct {
+	name    string
+	args    string // 位置参数说明，用于帮助
+	summary string
+	groups  []string
+	// extra 注册该子命令独有的参数
+	extra func(fs *flag.FlagSet, opts *model.Options)
+	// check 校验位置参数并写入 opts
+	check func(args []string, opts *model.Options) error
+}
+
+var commands = []commandDef{
+	{
+		name:    CmdCheck,
+		summary: "检测账号并导出结果，不做任何处置",
+		groups:  []string{groupConn, groupSelect, groupProbe},
+	},
+	{
+		name:    CmdDelete,
+		summary: "检测并处置失效账号；--from-output 跳过检测，直接处置 output 文件中的账号",
+		groups:  []string{groupConn, groupSelect, groupProbe, groupDelete},
+		extra: func(fs *flag.FlagSet, opts *model.Options) {
+			fs.BoolVar(&opts.DeleteFromOutput, "from-output", false, "从 output 文件读取账号直接处置（跳过401检测）")
+		},
+		check: func(_ []string, opts *model.Options) error {
+			opts.Delete = !opts.DeleteFromOutput
+			return nil
+		},
+	},
+	{
+		name:    CmdDaemon,
+		summary: "按 --cron（或配置文件 cron）定时检测并自动处置，无人值守",
+		groups:  []string{groupConn, groupSelect, groupProbe, groupDelete, groupCron},
+	},
+	{
+		name:    CmdList,
+		summary: "列出管理服务中匹配的账号清单，不做探测",
+		groups:  []string{groupConn, groupSelect},
+		extra: func(fs *flag.FlagSet, opts *model.Options) {
+			fs.StringVar(&opts.ListFormat, "format", "table", "输出格式: table / json / csv")
+			fs.StringVar(&opts.ListColumns, "columns", "", "输出列（逗号分隔），默认 "+inventory.DefaultColumns+"；可选 target、chatgpt_account_id 及任意 auth 文件字段（可用 . 访问嵌套字段）")
+			fs.StringVar(&opts.ListGroupBy, "group-by", "", "按这些列分组计数（逗号分隔，例如 type,provider），只输出每组数量")
+		},
+	},
+	{
+		name:    CmdInspect,
+		args:    "<账号>...",
+		summary: "查看账号详情与探测历史；账号可为 name、account 或 auth_index",
+		groups:  []string{groupConn, groupHistory},
+		extra: func(fs *flag.FlagSet, opts *model.Options) {
+			fs.BoolVar(&opts.InspectProbe, "probe", false, "同时立即探测一次（只读，不写入探测历史）")
+		},
+		check: func(args []string, _ *model.Options) error {
+			if len(args) == 0 {
+				return errors.New("缺少账号参数")
+			}
+			return nil
+		},
+	},
+	{
+		name:    CmdRestore,
+		args:    "<备份目录>",
+		summary: "将备份目录（auth_backups/<时间戳>）中的 auth 文件重新上传",
+		groups:  []string{groupConn},
+		extra: func(fs *flag.FlagSet, opts *model.Options) {
+			fs.StringVar(&opts.RestoreNames, "names", "", "仅恢复这些账号（逗号分隔）")
+			fs.BoolVar(&opts.RestoreForce, "force", false, "备份来自其他管理服务（base-url 不一致）时仍恢复到当前服务")
+		},
+		check: func(args []string, opts *model.Options) error {
+			if len(args) != 1 {
+				return errors.New("需要且只能指定一个备份目录")
+			}
+			opts.RestoreDir = args[0]
+			return nil
+		},
+	},
+	{
+		name:    CmdConfig,
+		summary: "校验并输出合并配置文件、HAR 与参数后的最终配置（token 脱敏）",
+		groups:  []string{groupConn, groupSelect, groupProbe, groupDelete, groupCron},
+	},
+}
+
+func findCommand(name string) *commandDef {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// Parse 解析命令行。首个参数为子命令时按子命令解析，否则按旧版参数形式交给 ParseFlags。
+// 请求帮助（-h / help）时把帮助写入 out 并返回 flag.ErrHelp
+func Parse(args []string, out io.Writer) (*Command, error) {
+	if len(args) > 0 && isHelp(args[0]) {
+		Usage(out)
+		return nil, flag.ErrHelp
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return &Command{Opts: ParseFlags(args)}, nil
+	}
+
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			if def := findCommand(args[1]); def != nil {
+				def.usage(out)
+				return nil, flag.ErrHelp
+			}
+		}
+		Usage(out)
+		return nil, flag.ErrHelp
+	}
+	def := findCommand(name)
+	if def == nil {
+		return nil, fmt.Errorf("未知子命令 %q（%s help 查看可用子命令）", name, program)
+	}
+
+	opts := defaultOptions()
+	fs := def.flagSet(opts)
+	rest, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		def.usage(out)
+		return nil, err
+	}
+	if err == nil && def.args == "" && len(rest) > 0 {
+		err = fmt.Errorf("多余的参数 %q", rest[0])
+	}
+	if err == nil && def.check != nil {
+		err = def.check(rest, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v（%s %s -h 查看帮助）", name, err, program, name)
+	}
+	return &Command{Name: name, Opts: opts, Args: rest}, nil
+}
+
+func isHelp(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *commandDef) flagSet(opts *model.Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(program+" "+d.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	register(fs, opts, d.groups...)
+	if d.extra != nil {
+		d.extra(fs, opts)
+	}
+	return fs
+}
+
+func (d *commandDef) usage(w io.Writer) {
+	line := program + " " + d.name + " [参数]"
+	if d.args != "" {
+		line += " " + d.args
+	}
+	_, _ = fmt.Fprintf(w, "用法: %s\n\n%s\n\n参数:\n", line, d.summary)
+	fs := d.flagSet(defaultOptions())
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// parseInterspersed 允许位置参数与参数交替出现，例如 restore <目录> --names a,b
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest, nil
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// Usage 输出子命令总览
+func Usage(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "用法: %s <子命令> [参数]\n\n子命令:\n", program)
+	for _, d := range commands {
+		name := d.name
+		if d.args != "" {
+			name += " " + d.args
+		}
+		_, _ = fmt.Fprintf(w, "  %s%s %s\n", name, strings.Repeat(" ", max(0, 20-displayWidth(name))), d.summary)
+	}
+	_, _ = fmt.Fprintf(w, "\n使用 %s <子命令> -h 查看各子命令的参数。\n", program)
+	_, _ = fmt.Fprintln(w, "旧版参数形式（--delete、--delete-from-output、--cron、--restore，无参数时进入交互菜单）仍可使用。")
+}
+
+// displayWidth 按终端显示宽度计算，中日韩字符占两列
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x2E80 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLegacyFlags(t *testing.T) {
+	cmd, err := Parse([]string{"--delete", "--yes", "--workers", "5"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "" || !cmd.Opts.Delete || !cmd.Opts.Yes || cmd.Opts.Workers != 5 {
+		t.Fatalf("unexpected legacy parse: %+v %+v", cmd, cmd.Opts)
+	}
+}
+
+func TestParseSubcommands(t *testing.T) {
+	cmd, err := Parse([]string{"delete", "--from-output", "--yes"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != CmdDelete || !cmd.Opts.DeleteFromOutput || cmd.Opts.Delete || !cmd.Opts.Yes {
+		t.Fatalf("unexpected delete parse: %+v", cmd.Opts)
+	}
+
+	cmd, err = Parse([]string{"restore", "backups/20260101", "--names", "a,b"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Opts.RestoreDir != "backups/20260101" || cmd.Opts.RestoreNames != "a,b" {
+		t.Fatalf("unexpected restore parse: %+v", cmd.Opts)
+	}
+
+	// 未注册的参数也取默认值，保证 MergeOptions 判断一致
+	cmd, err = Parse([]string{"list"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Opts.Workers != 120 || cmd.Opts.QuarantineWindow != 24*time.Hour || cmd.Opts.CanaryMode != "report" {
+		t.Fatalf("defaults not applied: %+v", cmd.Opts)
+	}
+}
+
+func TestParseSubcommandErrors(t *testing.T) {
+	cases := [][]string{
+		{"bogus"},
+		{"check", "--delete"},   // 旧版参数不属于 check
+		{"list", "--cron", "x"}, // 调度参数只属于 daemon
+		{"restore"},
+		{"inspect"},
+		{"check", "extra"},
+	}
+	for _, args := range cases {
+		if _, err := Parse(args, &bytes.Buffer{}); err == nil || errors.Is(err, flag.ErrHelp) {
+			t.Fatalf("%v: expected error, got %v", args, err)
+		}
+	}
+}
+
+func TestParseHelp(t *testing.T) {
+	out := &bytes.Buffer{}
+	if _, err := Parse([]string{"--help"}, out); !errors.Is(err, flag.ErrHelp) || !strings.Contains(out.String(), "inspect") {
+		t.Fatalf("expected overview, err=%v out=%s", err, out.String())
+	}
+	out.Reset()
+	if _, err := Parse([]string{"daemon", "-h"}, out); !errors.Is(err, flag.ErrHelp) || !strings.Contains(out.String(), "-cron") || strings.Contains(out.String(), "-restore string") {
+		t.Fatalf("expected daemon help, err=%v out=%s", err, out.String())
+	}
+}
@@ -3,32 +3,111 @@ package cli
 import (
 	"flag"
 	"os"
+	"time"
 
 	"clean_codex_token/internal/model"
 )
 
+// 参数分组；子命令只注册与自己相关的分组，旧版参数形式注册全部
+const (
+	groupConn    = "conn"    // 管理服务连接、配置文件、日志
+	groupSelect  = "select"  // 账号筛选
+	groupProbe   = "probe"   // 探测、导出、报告、指标
+	groupDelete  = "delete"  // 处置策略与安全阈值
+	groupCron    = "cron"    // 定时调度
+	groupLegacy  = "legacy"  // 仅旧版参数形式：--delete / --delete-from-output / --restore
+	groupHistory = "history" // 探测历史（inspect 只需要这一项）
+)
+
+var allGroups = []string{groupConn, groupSelect, groupProbe, groupDelete, groupCron, groupLegacy}
+
+// ParseFlags 解析旧版参数形式（无子命令）；未知参数被忽略以兼容旧脚本
 func ParseFlags(args []string) *model.Options {
 	fs := flag.NewFlagSet("clean-codex-accounts", flag.ContinueOnError)
 	opts := &model.Options{}
-
-	fs.StringVar(&opts.ConfigPath, "config", model.DefaultConfigPath, "配置文件路径（默认: config.json）")
-	fs.StringVar(&opts.BaseURL, "base-url", model.DefaultBaseURL, "")
-	fs.StringVar(&opts.Token, "token", os.Getenv("MGMT_TOKEN"), "")
-	fs.StringVar(&opts.HarPath, "har", "", "从浏览器导出的 HAR 自动提取 token/base-url/UA/Chatgpt-Account-Id")
-	fs.StringVar(&opts.TargetType, "target-type", "codex", "按 files[].type（或 typo）过滤")
-	fs.StringVar(&opts.Provider, "provider", "", "可选：再按 provider 过滤")
-	fs.IntVar(&opts.Workers, "workers", 120, "并发数（401检测）")
-	fs.IntVar(&opts.DeleteWorkers, "delete-workers", 20, "并发数（删除）")
-	fs.IntVar(&opts.Timeout, "timeout", model.DefaultTimeout, "每次请求超时秒数")
-	fs.IntVar(&opts.Retries, "retries", 1, "单账号探测失败重试次数")
-	fs.StringVar(&opts.UserAgent, "user-agent", model.DefaultUA, "")
-	fs.StringVar(&opts.ChatgptAccountID, "chatgpt-account-id", os.Getenv("CHATGPT_ACCOUNT_ID"), "")
-	fs.StringVar(&opts.Output, "output", model.DefaultOutput, "")
-	fs.StringVar(&opts.Cron, "cron", "", "cron表达式（5段），开启后以无人值守方式定时执行401检测并删除")
-	fs.BoolVar(&opts.Delete, "delete", false, "开启后执行删除")
-	fs.BoolVar(&opts.DeleteFromOutput, "delete-from-output", false, "从 output 文件读取账号直接删除（跳过401检测）")
-	fs.BoolVar(&opts.Yes, "yes", false, "删除时跳过二次确认")
-
+	register(fs, opts, allGroups...)
 	_ = fs.Parse(args)
 	return opts
 }
+
+// defaultOptions 返回全部字段为参数默认值的 Options；子命令未注册的参数也取默认值，
+// 保证 MergeOptions 依据默认值判断“未指定”时与旧版参数形式一致
+func defaultOptions() *model.Options {
+	opts := &model.Options{}
+	register(flag.NewFlagSet("defaults", flag.ContinueOnError), opts, allGroups...)
+	return opts
+}
+
+// register 在 fs 上注册 groups 中的参数
+func register(fs *flag.FlagSet, opts *model.Options, groups ...string) {
+	has := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		has[g] = true
+	}
+
+	if has[groupConn] {
+		fs.StringVar(&opts.ConfigPath, "config", model.DefaultConfigPath, "配置文件路径（默认: config.json）")
+		fs.StringVar(&opts.BaseURL, "base-url", model.DefaultBaseURL, "")
+		fs.StringVar(&opts.Token, "token", os.Getenv("MGMT_TOKEN"), "")
+		fs.StringVar(&opts.HarPath, "har", "", "从浏览器导出的 HAR 自动提取 token/base-url/UA/Chatgpt-Account-Id")
+		fs.IntVar(&opts.Timeout, "timeout", model.DefaultTimeout, "每次请求超时秒数")
+		fs.StringVar(&opts.UserAgent, "user-agent", model.DefaultUA, "")
+		fs.StringVar(&opts.ChatgptAccountID, "chatgpt-account-id", os.Getenv("CHATGPT_ACCOUNT_ID"), "")
+		fs.StringVar(&opts.TargetNames, "targets", "", "多目标模式下只处理配置文件 targets 中的指定目标（逗号分隔名称）")
+		fs.StringVar(&opts.LogFormat, "log-format", "console", "日志格式: console（纯文本，适合人工查看）/ text / json（slog 结构化，适合日志采集）")
+		fs.StringVar(&opts.LogLevel, "log-level", "info", "日志级别: debug / info / warn / error；debug 会输出每个账号的探测/删除事件")
+	}
+	if has[groupSelect] {
+		fs.StringVar(&opts.TargetType, "target-type", "codex", "按 files[].type（或 typo）过滤")
+		fs.StringVar(&opts.Provider, "provider", "", "可选：再按 provider 过滤")
+		fs.StringVar(&opts.Filter, "filter", "", `可选：再按表达式过滤，例如 'email ~ "*@corp.com" && created_at < 2026-01-01'`)
+	}
+	if has[groupProbe] || has[groupHistory] {
+		fs.StringVar(&opts.HistoryPath, "history", "", "探测历史文件（默认与 output 同目录的 probe_history.json）")
+	}
+	if has[groupProbe] {
+		fs.IntVar(&opts.Workers, "workers", 120, "并发数（401检测）")
+		fs.IntVar(&opts.Retries, "retries", 1, "单账号探测失败重试次数（指数退避，遵循 Retry-After）")
+		fs.Float64Var(&opts.ProbeRPS, "probe-rps", 0, "所有 worker 共享的探测速率上限（次/秒，0 为不限速）")
+		fs.StringVar(&opts.Output, "output", model.DefaultOutput, "")
+		fs.StringVar(&opts.OutputFormat, "output-format", "json", "output 导出格式: json / ndjson（逐条写入）/ csv / markdown")
+		fs.StringVar(&opts.PolicySpec, "policy", "", "按结论覆盖处置方式，例如 unauthorized=delete,forbidden=quarantine（可选 keep/delete/quarantine）")
+		fs.IntVar(&opts.CoolingCycles, "cooling-cycles", 3, "额度耗尽的账号先冷却到额度重置时间再复探，经历该次数重置后仍耗尽才按 policy 处置；0 为不冷却")
+		fs.StringVar(&opts.Canaries, "canaries", "", "金丝雀账号（name、account 或 auth_index，逗号分隔）：已知正常，探测前先探测、结束后复查，任一失败即拒绝本轮删除")
+		fs.StringVar(&opts.CanaryMode, "canary-mode", "report", "金丝雀未通过时: report（继续探测并导出，仅不处置）/ abort（立即结束本轮）")
+		fs.StringVar(&opts.ReportPath, "report", "", "运行报告 JSON 文件：参数（token 脱敏）、起止时间、全部账号探测结果与耗时、删除记录与汇总；cron 模式每轮覆盖")
+		fs.StringVar(&opts.MetricsAddr, "metrics-addr", "", "Prometheus 指标监听地址，例如 :9090（暴露 /metrics，默认不开启）")
+		fs.DurationVar(&opts.DrainTimeout, "drain-timeout", 30*time.Second, "收到 SIGINT/SIGTERM 后等待在途请求完成的最长时间")
+	}
+	if has[groupDelete] {
+		fs.StringVar(&opts.Allow, "allow", "", "只允许处置这些账号（name，逗号分隔，支持 * ? 通配），其他账号只检测不处置")
+		fs.StringVar(&opts.Protect, "protect", "", "永不处置的账号（name，逗号分隔，支持 * ? 通配），仍会检测并导出")
+		fs.IntVar(&opts.DeleteWorkers, "delete-workers", 20, "并发数（删除）")
+		fs.StringVar(&opts.Strategy, "strategy", model.StrategyDelete, "失效账号处置策略: delete | disable | quarantine")
+		fs.IntVar(&opts.QuarantineFails, "quarantine-failures", 3, "disable/quarantine 策略下，隔离后连续失败达到该次数才删除")
+		fs.DurationVar(&opts.QuarantineWindow, "quarantine-window", 24*time.Hour, "disable/quarantine 策略下，隔离至少持续该时长才删除")
+		fs.StringVar(&opts.BackupDir, "backup-dir", "", "删除前备份 auth 文件的目录（默认与 output 同目录的 auth_backups）")
+		fs.BoolVar(&opts.NoBackup, "no-backup", false, "删除前不备份 auth 文件")
+		fs.BoolVar(&opts.Yes, "yes", false, "删除时跳过二次确认")
+		fs.DurationVar(&opts.MaxOutputAge, "max-output-age", 24*time.Hour, "从 output 删除（delete --from-output / --delete-from-output）时拒绝生成时间早于该时长的 output 文件，0 为不检查")
+		fs.BoolVar(&opts.Reverify, "reverify", false, "删除前重新探测每个账号，已恢复正常的跳过删除")
+		fs.Float64Var(&opts.MaxDeleteRatio, "max-delete-ratio", 0, "安全阈值：待处置账号占匹配账号的比例超过该值（0~1）时拒绝本轮删除，0 为不限制")
+		fs.IntVar(&opts.MaxDelete, "max-delete", 0, "安全阈值：待处置账号数超过该值时拒绝本轮删除，0 为不限制")
+		fs.IntVar(&opts.MinKeep, "min-keep", 0, "安全阈值：处置后剩余的健康账号数低于该值时拒绝本轮删除，0 为不限制")
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "演练模式：走完整流程但不真正删除，仅输出并导出将被删除的账号")
+		fs.StringVar(&opts.PlanOutput, "plan-output", "", "dry-run 删除计划导出文件（默认与 output 同目录的 dry_run_delete_plan.json）")
+	}
+	if has[groupCron] {
+		fs.StringVar(&opts.Cron, "cron", "", "cron表达式（5段，支持 @hourly/@daily、JAN/MON、CRON_TZ= 前缀），开启后以无人值守方式定时执行401检测并删除")
+		fs.StringVar(&opts.CronTZ, "cron-tz", "", "cron 使用的时区，例如 Asia/Shanghai（默认本地时区）")
+		fs.StringVar(&opts.Listen, "listen", "", "daemon 控制接口监听地址，例如 127.0.0.1:8321（默认不开启，需配合 --api-token）")
+		fs.StringVar(&opts.APIToken, "api-token", os.Getenv("CLEAN_CODEX_API_TOKEN"), "控制接口的访问 token（Authorization: Bearer <token>）")
+	}
+	if has[groupLegacy] {
+		fs.BoolVar(&opts.Delete, "delete", false, "开启后执行删除")
+		fs.BoolVar(&opts.DeleteFromOutput, "delete-from-output", false, "从 output 文件读取账号直接删除（跳过401检测）")
+		fs.StringVar(&opts.RestoreDir, "restore", "", "将指定备份目录（auth_backups/<时间戳>）中的 auth 文件重新上传")
+		fs.StringVar(&opts.RestoreNames, "restore-names", "", "配合 --restore：仅恢复这些账号（逗号分隔）")
+		fs.BoolVar(&opts.RestoreForce, "restore-force", false, "配合 --restore：备份来自其他管理服务（base-url 不一致）时仍恢复到当前服务")
+	}
+}
@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"path/filepath"
 	"strings"
+	"time"
 
 	"clean_codex_token/internal/model"
 )
@@ -28,6 +30,15 @@ func MergeOptions(opts *model.Options, conf map[string]any, harCtx *model.HarCon
 	if v, ok := conf["provider"].(string); ok && v != "" && opts.Provider == "" {
 		opts.Provider = v
 	}
+	if v, ok := conf["filter"].(string); ok && v != "" && opts.Filter == "" {
+		opts.Filter = v
+	}
+	if v := asList(conf["allow"]); v != "" && opts.Allow == "" {
+		opts.Allow = v
+	}
+	if v := asList(conf["protect"]); v != "" && opts.Protect == "" {
+		opts.Protect = v
+	}
 	if v, ok := asInt(conf["workers"]); ok && opts.Workers == 120 {
 		opts.Workers = v
 	}
@@ -40,12 +51,82 @@ func MergeOptions(opts *model.Options, conf map[string]any, harCtx *model.HarCon
 	if v, ok := asInt(conf["retries"]); ok && opts.Retries == 1 {
 		opts.Retries = v
 	}
+	if v, ok := conf["probe_rps"].(float64); ok && opts.ProbeRPS == 0 {
+		opts.ProbeRPS = v
+	}
 	if v, ok := conf["output"].(string); ok && v != "" && opts.Output == model.DefaultOutput {
 		opts.Output = v
 	}
+	if v, ok := conf["output_format"].(string); ok && v != "" && opts.OutputFormat == "json" {
+		opts.OutputFormat = v
+	}
+	if v, ok := conf["report"].(string); ok && v != "" && opts.ReportPath == "" {
+		opts.ReportPath = v
+	}
+	if v, ok := conf["history"].(string); ok && v != "" && opts.HistoryPath == "" {
+		opts.HistoryPath = v
+	}
+	if v, ok := conf["strategy"].(string); ok && v != "" && opts.Strategy == model.StrategyDelete {
+		opts.Strategy = v
+	}
+	if v, ok := asInt(conf["quarantine_failures"]); ok && opts.QuarantineFails == 3 {
+		opts.QuarantineFails = v
+	}
+	if v, ok := conf["quarantine_window"].(string); ok && v != "" && opts.QuarantineWindow == 24*time.Hour {
+		if d, err := time.ParseDuration(v); err == nil {
+			opts.QuarantineWindow = d
+		}
+	}
+	if v, ok := conf["backup_dir"].(string); ok && v != "" && opts.BackupDir == "" {
+		opts.BackupDir = v
+	}
 	if v, ok := conf["cron"].(string); ok && v != "" && opts.Cron == "" {
 		opts.Cron = v
 	}
+	if v, ok := conf["cron_tz"].(string); ok && v != "" && opts.CronTZ == "" {
+		opts.CronTZ = v
+	}
+	if v, ok := conf["log_format"].(string); ok && v != "" && opts.LogFormat == "console" {
+		opts.LogFormat = v
+	}
+	if v, ok := conf["log_level"].(string); ok && v != "" && opts.LogLevel == "info" {
+		opts.LogLevel = v
+	}
+	if v, ok := conf["max_delete_ratio"].(float64); ok && opts.MaxDeleteRatio == 0 {
+		opts.MaxDeleteRatio = v
+	}
+	if v, ok := asInt(conf["max_delete"]); ok && opts.MaxDelete == 0 {
+		opts.MaxDelete = v
+	}
+	if v, ok := asInt(conf["min_keep"]); ok && opts.MinKeep == 0 {
+		opts.MinKeep = v
+	}
+	if v := asList(conf["canaries"]); v != "" && opts.Canaries == "" {
+		opts.Canaries = v
+	}
+	if v, ok := conf["max_output_age"].(string); ok && v != "" && opts.MaxOutputAge == 24*time.Hour {
+		if d, err := time.ParseDuration(v); err == nil {
+			opts.MaxOutputAge = d
+		}
+	}
+	if v, ok := conf["reverify"].(bool); ok && v {
+		opts.Reverify = true
+	}
+	if v, ok := conf["canary_mode"].(string); ok && v != "" && opts.CanaryMode == "report" {
+		opts.CanaryMode = v
+	}
+	if v, ok := asInt(conf["cooling_cycles"]); ok && opts.CoolingCycles == 3 {
+		opts.CoolingCycles = v
+	}
+	if v, ok := conf["metrics_addr"].(string); ok && v != "" && opts.MetricsAddr == "" {
+		opts.MetricsAddr = v
+	}
+	if v, ok := conf["listen"].(string); ok && v != "" && opts.Listen == "" {
+		opts.Listen = v
+	}
+	if v, ok := conf["api_token"].(string); ok && v != "" && opts.APIToken == "" {
+		opts.APIToken = v
+	}
 
 	if harCtx != nil {
 		if opts.Token == "" && harCtx.Token != "" {
@@ -62,12 +143,40 @@ func MergeOptions(opts *model.Options, conf map[string]any, harCtx *model.HarCon
 		}
 	}
 
+	if opts.HistoryPath == "" {
+		opts.HistoryPath = filepath.Join(filepath.Dir(opts.Output), model.DefaultHistory)
+	}
+	if opts.BackupDir == "" {
+		opts.BackupDir = filepath.Join(filepath.Dir(opts.Output), model.DefaultBackupDir)
+	}
+	if opts.PlanOutput == "" {
+		opts.PlanOutput = filepath.Join(filepath.Dir(opts.Output), model.DefaultPlanOutput)
+	}
+
 	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
 	if opts.BaseURL == "" {
 		opts.BaseURL = strings.TrimRight(model.DefaultBaseURL, "/")
 	}
 }
 
+// asList 接受字符串数组或逗号分隔字符串，统一为逗号分隔
+func asList(v any) string {
+	switch l := v.(type) {
+	case string:
+		return l
+	case []any:
+		parts := make([]string, 0, len(l))
+		for _, e := range l {
+			if s, ok := e.(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ",")
+	default:
+		return ""
+	}
+}
+
 func asInt(v any) (int, bool) {
 	switch n := v.(type) {
 	case float64:
@@ -56,3 +56,23 @@ func TestMergeOptionsPriorityRules(t *testing.T) {
 		t.Fatalf("output merge failed: %q", opts.Output)
 	}
 }
+
+func TestMergeOptionsGuard(t *testing.T) {
+	opts := &model.Options{Output: model.DefaultOutput, MaxDelete: 5}
+	conf := map[string]any{
+		"max_delete_ratio": 0.3,
+		"max_delete":       float64(50),
+		"min_keep":         float64(10),
+		"canaries":         []any{"keep-a", "keep-b"},
+	}
+	MergeOptions(opts, conf, nil)
+	if opts.MaxDeleteRatio != 0.3 || opts.MinKeep != 10 {
+		t.Fatalf("guard merge failed: %+v", opts)
+	}
+	if opts.MaxDelete != 5 {
+		t.Fatalf("flag should win over config, got %d", opts.MaxDelete)
+	}
+	if opts.Canaries != "keep-a,keep-b" {
+		t.Fatalf("canaries merge failed: %q", opts.Canaries)
+	}
+}
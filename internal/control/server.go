@@ -0,0 +1,138 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"clean_codex_token/internal/report"
+)
+
+// ErrNotFound 账号不存在；Daemon 返回的错误包装它时响应 404
+var ErrNotFound = errors.New("未找到账号")
+
+// Status daemon 当前状态
+type Status struct {
+	Cron    string   `json:"cron"`
+	Targets []string `json:"targets,omitempty"`
+	// Running 正在执行一轮探测/删除
+	Running bool `json:"running"`
+	// DeletePaused 删除已暂停：每轮只探测、导出，不处置账号
+	DeletePaused bool       `json:"delete_paused"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// Daemon 控制接口操作的 daemon，由 app 实现
+type Daemon interface {
+	Status() Status
+	// Trigger 请求立即执行一轮；正在执行时返回 false
+	Trigger() bool
+	// PauseDeletion 暂停（true）或恢复（false）定时删除
+	PauseDeletion(paused bool)
+	// Reports 各目标最近一轮的运行报告，尚未执行过时为空
+	Reports() []*report.Report
+	// Account 按 name、account 或 auth_index 查找账号及其探测历史，probe 为 true 时即时探测（不写入历史）
+	Account(ctx context.Context, key string, probe bool) (any, error)
+	// Config 合并后的最终配置，token 已脱敏
+	Config() any
+}
+
+// Handler daemon 模式的 HTTP 控制接口，所有请求都需要 Authorization: Bearer <token>；token 为空时拒绝所有请求
+func Handler(token string, d Daemon) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /api/v1/run", func(w http.ResponseWriter, r *http.Request) {
+		if !d.Trigger() {
+			writeError(w, http.StatusConflict, "正在执行，请稍后再试")
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]bool{"triggered": true})
+	})
+	mux.HandleFunc("GET /api/v1/report", func(w http.ResponseWriter, r *http.Request) {
+		reps := d.Reports()
+		if len(reps) == 0 {
+			writeError(w, http.StatusNotFound, "尚未执行过")
+			return
+		}
+		writeJSON(w, http.StatusOK, reps)
+	})
+	account := func(probe bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			v, err := d.Account(r.Context(), r.PathValue("key"), probe)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				writeError(w, http.StatusNotFound, err.Error())
+			case err != nil:
+				writeError(w, http.StatusBadGateway, err.Error())
+			default:
+				writeJSON(w, http.StatusOK, v)
+			}
+		}
+	}
+	mux.HandleFunc("GET /api/v1/accounts/{key}", account(false))
+	mux.HandleFunc("POST /api/v1/accounts/{key}/probe", account(true))
+	mux.HandleFunc("POST /api/v1/deletion/pause", func(w http.ResponseWriter, r *http.Request) {
+		d.PauseDeletion(true)
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /api/v1/deletion/resume", func(w http.ResponseWriter, r *http.Request) {
+		d.PauseDeletion(false)
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("GET /api/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Config())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="clean-codex-accounts"`)
+			writeError(w, http.StatusUnauthorized, "未授权：缺少或错误的 API token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func authorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) == 1
+}
+
+// Serve 在 addr 上提供控制接口，ctx 结束后关闭；监听失败立即返回错误
+func Serve(ctx context.Context, addr, token string, d Daemon) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: Handler(token, d), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go srv.Serve(ln)
+	return ln.Addr(), nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,129 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"clean_codex_token/internal/report"
+)
+
+type fakeDaemon struct {
+	running  bool
+	paused   bool
+	triggers int
+	reports  []*report.Report
+}
+
+func (f *fakeDaemon) Status() Status {
+	return Status{Cron: "*/5 * * * *", Running: f.running, DeletePaused: f.paused}
+}
+
+func (f *fakeDaemon) Trigger() bool {
+	if f.running {
+		return false
+	}
+	f.triggers++
+	return true
+}
+
+func (f *fakeDaemon) PauseDeletion(paused bool) { f.paused = paused }
+
+func (f *fakeDaemon) Reports() []*report.Report { return f.reports }
+
+func (f *fakeDaemon) Account(ctx context.Context, key string, probe bool) (any, error) {
+	if key != "a" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return map[string]any{"name": key, "probed": probe}, nil
+}
+
+func (f *fakeDaemon) Config() any { return map[string]string{"token": "<redacted>"} }
+
+func TestHandler(t *testing.T) {
+	d := &fakeDaemon{}
+	h := Handler("secret", d)
+	call := func(method, path, token string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var body map[string]any
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+			var raw any
+			_ = json.Unmarshal(rec.Body.Bytes(), &raw)
+			body, _ = raw.(map[string]any)
+		}
+		return rec.Code, body
+	}
+
+	if code, _ := call("GET", "/api/v1/status", ""); code != http.StatusUnauthorized {
+		t.Fatalf("missing token should be rejected, got %d", code)
+	}
+	if code, _ := call("GET", "/api/v1/status", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token should be rejected, got %d", code)
+	}
+	if code, body := call("GET", "/api/v1/status", "secret"); code != http.StatusOK || body["cron"] != "*/5 * * * *" {
+		t.Fatalf("status: %d %v", code, body)
+	}
+
+	if code, _ := call("POST", "/api/v1/run", "secret"); code != http.StatusAccepted || d.triggers != 1 {
+		t.Fatalf("trigger: %d triggers=%d", code, d.triggers)
+	}
+	d.running = true
+	if code, _ := call("POST", "/api/v1/run", "secret"); code != http.StatusConflict {
+		t.Fatalf("trigger while running should conflict, got %d", code)
+	}
+	if code, _ := call("GET", "/api/v1/run", "secret"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET run should not be allowed, got %d", code)
+	}
+
+	if code, body := call("POST", "/api/v1/deletion/pause", "secret"); code != http.StatusOK || body["delete_paused"] != true || !d.paused {
+		t.Fatalf("pause: %d %v", code, body)
+	}
+	if code, _ := call("POST", "/api/v1/deletion/resume", "secret"); code != http.StatusOK || d.paused {
+		t.Fatalf("resume: %d paused=%t", code, d.paused)
+	}
+
+	if code, _ := call("GET", "/api/v1/report", "secret"); code != http.StatusNotFound {
+		t.Fatalf("report before any run should be 404, got %d", code)
+	}
+	if code, _ := call("GET", "/api/v1/accounts/a", "secret"); code != http.StatusOK {
+		t.Fatalf("account: %d", code)
+	}
+	if code, body := call("POST", "/api/v1/accounts/a/probe", "secret"); code != http.StatusOK || body["probed"] != true {
+		t.Fatalf("probe: %d %v", code, body)
+	}
+	if code, body := call("GET", "/api/v1/accounts/missing", "secret"); code != http.StatusNotFound || !strings.Contains(fmt.Sprint(body["error"]), "missing") {
+		t.Fatalf("missing account: %d %v", code, body)
+	}
+	if code, body := call("GET", "/api/v1/config", "secret"); code != http.StatusOK || body["token"] != "<redacted>" {
+		t.Fatalf("config: %d %v", code, body)
+	}
+}
+
+func TestServeRejectsEmptyToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, err := Serve(ctx, "127.0.0.1:0", "", &fakeDaemon{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://"+addr.String()+"/api/v1/status", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("empty token must not authorize, got %d", resp.StatusCode)
+	}
+}
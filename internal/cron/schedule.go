@@ -0,0 +1,200 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 标准 5 段 cron 表达式（分 时 日 月 周）
+type Schedule struct {
+	Expr     string
+	Location *time.Location
+
+	minute bitset
+	hour   bitset
+	dom    bitset
+	month  bitset
+	dow    bitset
+	// 日、周均被限制（都不以 * 开头）时，二者满足其一即可（标准 cron 的 OR 语义）
+	domStar bool
+	dowStar bool
+}
+
+type bitset uint64
+
+func (b bitset) has(v int) bool { return b&(1<<uint(v)) != 0 }
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dowNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// Parse 解析表达式；支持 CRON_TZ=/TZ= 前缀、@hourly 等宏、a-b/n、月份与星期英文缩写、7 表示周日。
+// 表达式未指定时区时使用 loc（nil 为本地时区）。
+func Parse(expr string, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexAny(spec, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("缺少时区后的表达式")
+		}
+		name := spec[strings.Index(spec, "=")+1 : i]
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("未知时区 %q: %w", name, err)
+		}
+		loc = l
+		spec = strings.TrimSpace(spec[i:])
+	}
+	if strings.HasPrefix(spec, "@") {
+		m, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("未知宏: %s", spec)
+		}
+		spec = m
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("需要 5 段表达式，例如 */5 * * * *")
+	}
+	s := &Schedule{Expr: strings.TrimSpace(expr), Location: loc}
+	var err error
+	if s.minute, err = parseField(parts[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseField(parts[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.dom, err = parseField(parts[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day-of-month: %w", err)
+	}
+	if s.month, err = parseField(parts[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	// 星期允许 0-7，7 与 0 都表示周日
+	if s.dow, err = parseField(parts[4], 0, 7, dowNames); err != nil {
+		return nil, fmt.Errorf("day-of-week: %w", err)
+	}
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(parts[2], "*")
+	s.dowStar = strings.HasPrefix(parts[4], "*")
+	return s, nil
+}
+
+func (s *Schedule) Match(t time.Time) bool {
+	t = t.In(s.Location)
+	return s.minute.has(t.Minute()) && s.hour.has(t.Hour()) && s.month.has(int(t.Month())) && s.dayMatches(t)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domOK := s.dom.has(t.Day())
+	dowOK := s.dow.has(int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next 返回严格晚于 t 的下一次触发时间；5 年内无匹配时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func parseField(part string, min, max int, names map[string]int) (bitset, error) {
+	var bits bitset
+	for _, seg := range strings.Split(part, ",") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			return 0, fmt.Errorf("空段")
+		}
+		rangePart, step := seg, 1
+		if i := strings.Index(seg, "/"); i >= 0 {
+			n, err := strconv.Atoi(seg[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("非法步长: %s", seg)
+			}
+			rangePart, step = seg[:i], n
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = min, max
+		case strings.Contains(rangePart, "-"):
+			r := strings.SplitN(rangePart, "-", 2)
+			a, err1 := parseValue(r[0], names)
+			b, err2 := parseValue(r[1], names)
+			if err1 != nil || err2 != nil || a > b || a < min || b > max {
+				return 0, fmt.Errorf("非法范围: %s", seg)
+			}
+			start, end = a, b
+		default:
+			n, err := parseValue(rangePart, names)
+			if err != nil || n < min || n > max {
+				return 0, fmt.Errorf("非法值: %s", seg)
+			}
+			start, end = n, n
+			// "5/15" 表示从 5 开始每 15 个单位
+			if strings.Contains(seg, "/") {
+				end = max
+			}
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	if bits == 0 {
+		return 0, fmt.Errorf("没有可用值")
+	}
+	return bits, nil
+}
+
+func parseValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(strings.TrimSpace(s))]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(s))
+}
@@ -0,0 +1,58 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	utc := time.UTC
+	base := time.Date(2026, 1, 1, 10, 7, 30, 0, utc) // 周四
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/10 * * * *", time.Date(2026, 1, 1, 10, 10, 0, 0, utc)},
+		{"@hourly", time.Date(2026, 1, 1, 11, 0, 0, 0, utc)},
+		{"@daily", time.Date(2026, 1, 2, 0, 0, 0, 0, utc)},
+		{"30 3 * * *", time.Date(2026, 1, 2, 3, 30, 0, 0, utc)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 1, 13, 0, 0, 0, utc)},
+		{"5/20 * * * *", time.Date(2026, 1, 1, 10, 25, 0, 0, utc)},
+		{"0 0 * FEB MON", time.Date(2026, 2, 2, 0, 0, 0, 0, utc)},
+		{"0 0 * * 7", time.Date(2026, 1, 4, 0, 0, 0, 0, utc)},
+		// 日、周同时限制时为 OR：15 号或周六
+		{"0 0 15 * SAT", time.Date(2026, 1, 3, 0, 0, 0, 0, utc)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, utc)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr, utc)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Fatalf("%s: next=%s want %s", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	s, err := Parse("CRON_TZ=Asia/Shanghai 30 8 * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next=%s want %s", got.UTC(), want)
+	}
+	if got.Location().String() != "Asia/Shanghai" {
+		t.Fatalf("expected Asia/Shanghai location, got %s", got.Location())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "@fortnightly", "5-1 * * * *", "CRON_TZ=Nowhere/City * * * * *"} {
+		if _, err := Parse(expr, time.UTC); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package deleter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/shutdown"
+)
+
+// quarantine 对候选账号执行隔离（disable 策略下通过管理接口禁用）：首次失效的账号禁用/隔离，已隔离且满足
+// “隔离后连续失败 >= MinFailures 且隔离时长 >= Window” 的账号返回为待删除
+func (s *Service) quarantine(ctx context.Context, candidates []model.DeleteCandidate) ([]model.DeleteCandidate, []model.DeleteResult) {
+	now := time.Now()
+	action := model.ActionQuarantine
+	if s.Strategy == model.StrategyDisable {
+		action = model.ActionDisable
+	}
+
+	confirmed := make([]model.DeleteCandidate, 0)
+	results := make([]model.DeleteResult, 0, len(candidates))
+	newly, holding, failed := 0, 0, 0
+	for _, c := range candidates {
+		if shutdown.Interrupted(ctx) {
+			break
+		}
+		rec, ok := s.History.Get(history.Key(c.Name, c.AuthIndex))
+		if ok && rec.QuarantinedAt != nil {
+			failures := s.failuresSinceQuarantine(rec)
+			if failures >= s.MinFailures && now.Sub(*rec.QuarantinedAt) >= s.Window {
+				confirmed = append(confirmed, c)
+				continue
+			}
+			holding++
+			s.Logger.Info(fmt.Sprintf("[隔离中] %s | 隔离后连续失败=%d/%d | 已隔离 %s", c.Name, failures, s.MinFailures, now.Sub(*rec.QuarantinedAt).Round(time.Second)),
+				"name", c.Name, "auth_index", c.AuthIndex, "failures_since_quarantine", failures, "quarantined_at", *rec.QuarantinedAt)
+			results = append(results, model.DeleteResult{Name: c.Name, Action: model.ActionHold, Verdict: c.Verdict, Reason: c.Reason, DryRun: s.DryRun})
+			continue
+		}
+
+		r := model.DeleteResult{Name: c.Name, Action: action, Verdict: c.Verdict, Reason: c.Reason, DryRun: s.DryRun}
+		if s.DryRun {
+			s.Logger.Info(fmt.Sprintf("[DRY-RUN] 将隔离(%s) %s | account=%s | auth_index=%s | verdict=%s | reason=%s", action, c.Name, c.Account, c.AuthIndex, c.Verdict, c.Reason),
+				"name", c.Name, "account", c.Account, "auth_index", c.AuthIndex, "verdict", string(c.Verdict), "reason", c.Reason, "action", action, "dry_run", true)
+			newly++
+			results = append(results, r)
+			continue
+		}
+		if action == model.ActionDisable {
+			status, data, text, err := s.Client.SetDisabled(ctx, c.Name, true)
+			r.StatusCode = status
+			if err != nil || !isOK(status, data) {
+				if err != nil {
+					r.Error = err.Error()
+				} else {
+					r.Error = "disable failed, response=" + truncate(text)
+				}
+				failed++
+				s.Logger.Warn(fmt.Sprintf("[禁用失败] %s | %s", c.Name, r.Error), resultAttrs(r)...)
+				results = append(results, r)
+				continue
+			}
+		}
+		s.History.Quarantine(c.Name, c.AuthIndex, now, action == model.ActionDisable)
+		s.Logger.Debug(fmt.Sprintf("[已隔离] %s", c.Name), resultAttrs(r)...)
+		newly++
+		results = append(results, r)
+	}
+
+	if !s.DryRun {
+		if err := s.History.Save(); err != nil {
+			s.Logger.Error(fmt.Sprintf("保存历史失败: %v", err), "error", err)
+		}
+	}
+	s.Logger.Info(fmt.Sprintf("隔离完成: 新隔离=%d，隔离观察中=%d，隔离失败=%d，确认待删除=%d", newly, holding, failed, len(confirmed)),
+		"quarantined", newly, "holding", holding, "failed", failed, "confirmed", len(confirmed))
+	return confirmed, results
+}
+
+// ReleaseRecovered 解除已恢复正常（最近一次探测为 ok）的隔离账号，必要时重新启用
+func (s *Service) ReleaseRecovered(ctx context.Context) {
+	released := 0
+	for _, rec := range s.History.Quarantined() {
+		if rec.LastOutcome != model.VerdictHealthy {
+			continue
+		}
+		if s.DryRun {
+			s.Logger.Info(fmt.Sprintf("[DRY-RUN] 将解除隔离 %s", rec.Name), "name", rec.Name, "auth_index", rec.AuthIndex, "dry_run", true)
+			continue
+		}
+		if rec.Disabled {
+			status, data, text, err := s.Client.SetDisabled(ctx, rec.Name, false)
+			if err != nil || !isOK(status, data) {
+				if err == nil {
+					err = fmt.Errorf("enable failed, response=%s", truncate(text))
+				}
+				s.Logger.Warn(fmt.Sprintf("[启用失败] %s | %v", rec.Name, err), "name", rec.Name, "auth_index", rec.AuthIndex, "error", err)
+				continue
+			}
+		}
+		s.History.Release(rec.Key)
+		released++
+		s.Logger.Info(fmt.Sprintf("[解除隔离] %s 已恢复正常", rec.Name), "name", rec.Name, "auth_index", rec.AuthIndex)
+	}
+	if released > 0 {
+		if err := s.History.Save(); err != nil {
+			s.Logger.Error(fmt.Sprintf("保存历史失败: %v", err), "error", err)
+		}
+	}
+}
+
+// failuresSinceQuarantine 隔离之后最近连续的、Policy 处置方式不为 keep 的失败次数；
+// 隔离前的失败，以及限流、调用失败等不能据以删除的结论都不计入
+func (s *Service) failuresSinceQuarantine(rec history.Record) int {
+	n := 0
+	for i := len(rec.Events) - 1; i >= 0; i-- {
+		ev := rec.Events[i]
+		if !ev.At.After(*rec.QuarantinedAt) || ev.Outcome == model.VerdictHealthy || s.Policy.ActionFor(ev.Outcome) == model.PolicyKeep {
+			break
+		}
+		n++
+	}
+	return n
+}
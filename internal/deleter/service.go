@@ -4,107 +4,334 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
+	"time"
 
+	"clean_codex_token/internal/backup"
 	"clean_codex_token/internal/cli"
+	"clean_codex_token/internal/filter"
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/logging"
+	"clean_codex_token/internal/metrics"
 	"clean_codex_token/internal/mgmt"
 	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/output"
+	"clean_codex_token/internal/shutdown"
 )
 
 type Service struct {
 	Client *mgmt.Client
+	// DryRun 为 true 时只输出并导出删除计划，不调用删除接口
+	DryRun bool
+	// PlanOutput dry-run 删除计划导出路径，为空则不导出
+	PlanOutput string
+	// BackupDir 删除前备份 auth 文件的根目录，为空则不备份
+	BackupDir string
+
+	// Strategy 处置策略，见 model.Strategy*；disable/quarantine 先隔离，满足条件后才删除
+	Strategy    string
+	History     *history.Store
+	MinFailures int
+	Window      time.Duration
+	// Policy 判断隔离后的探测结论能否计入确认删除所需的失败次数
+	Policy model.Policy
+	// Metrics 为 nil 时不采集指标
+	Metrics *metrics.Recorder
+	Logger  *slog.Logger
+	// Allow 非 nil 时只处置匹配的账号；Protect 匹配的账号永不处置。两者都在处置前最后一步检查，
+	// 同样适用于 --delete-from-output 与隔离后确认删除
+	Allow   *filter.Names
+	Protect *filter.Names
+	// Reverify 非 nil 时删除每个账号前重新探测，结论为 healthy 的跳过删除
+	Reverify func(ctx context.Context, c model.DeleteCandidate) (model.ProbeResult, error)
+}
+
+func NewService(client *mgmt.Client, store *history.Store) *Service {
+	if store == nil {
+		store = history.NewMemory()
+	}
+	return &Service{Client: client, History: store, Strategy: model.StrategyDelete, Policy: model.DefaultPolicy(), Logger: logging.Discard()}
 }
 
-func NewService(client *mgmt.Client) *Service {
-	return &Service{Client: client}
+// Run 按策略处置候选账号；备份目录不可用等导致整批删除被取消时，结果中每个待删除账号都记为失败并返回错误
+func (s *Service) Run(ctx context.Context, candidates []model.DeleteCandidate, deleteWorkers int, needConfirm bool, in io.Reader, out io.Writer) ([]model.DeleteResult, error) {
+	candidates, protected := s.excludeProtected(candidates)
+	if len(candidates) == 0 {
+		s.Logger.Info("没有可删除账号。")
+		return protected, nil
+	}
+	// Policy 为 quarantine 的账号始终先隔离；disable/quarantine 策略下全部先隔离
+	quarantineAll := s.Strategy == model.StrategyDisable || s.Strategy == model.StrategyQuarantine
+	toQuarantine := make([]model.DeleteCandidate, 0)
+	toDelete := make([]model.DeleteCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if quarantineAll || c.Action == model.PolicyQuarantine {
+			toQuarantine = append(toQuarantine, c)
+		} else {
+			toDelete = append(toDelete, c)
+		}
+	}
+	if len(toQuarantine) == 0 {
+		deleted, err := s.deleteAll(ctx, toDelete, deleteWorkers, needConfirm, in, out)
+		return append(protected, deleted...), err
+	}
+
+	confirmed, held := s.quarantine(ctx, toQuarantine)
+	held = append(protected, held...)
+	toDelete = append(toDelete, confirmed...)
+	if len(toDelete) == 0 {
+		return held, nil
+	}
+	deleted, err := s.deleteAll(ctx, toDelete, deleteWorkers, needConfirm, in, out)
+	if !s.DryRun && len(confirmed) > 0 {
+		keys := make(map[string]string, len(confirmed))
+		for _, c := range confirmed {
+			keys[c.Name] = history.Key(c.Name, c.AuthIndex)
+		}
+		for _, r := range deleted {
+			if key, ok := keys[r.Name]; ok && r.Deleted {
+				s.History.Release(key)
+			}
+		}
+		if err := s.History.Save(); err != nil {
+			s.Logger.Error(fmt.Sprintf("保存历史失败: %v", err), "error", err)
+		}
+	}
+	return append(held, deleted...), err
 }
 
-func (s *Service) Run(ctx context.Context, names []string, deleteWorkers int, needConfirm bool, in io.Reader, out io.Writer, progress func(string)) []model.DeleteResult {
-	if len(names) == 0 {
-		progress("没有可删除账号。")
-		return nil
+// excludeProtected 剔除 Protect 匹配或不在 Allow 中的账号，记为跳过
+func (s *Service) excludeProtected(candidates []model.DeleteCandidate) ([]model.DeleteCandidate, []model.DeleteResult) {
+	if s.Allow == nil && s.Protect == nil {
+		return candidates, nil
+	}
+	kept := make([]model.DeleteCandidate, 0, len(candidates))
+	skipped := make([]model.DeleteResult, 0)
+	for _, c := range candidates {
+		reason := ""
+		switch {
+		case s.Protect.Match(c.Name):
+			reason = "skipped: protected"
+		case s.Allow != nil && !s.Allow.Match(c.Name):
+			reason = "skipped: not allowed"
+		default:
+			kept = append(kept, c)
+			continue
+		}
+		r := model.DeleteResult{Name: c.Name, Action: model.ActionSkip, Verdict: c.Verdict, Reason: reason}
+		s.Logger.Info(fmt.Sprintf("[跳过] %s | %s", c.Name, reason), resultAttrs(r)...)
+		skipped = append(skipped, r)
 	}
+	return kept, skipped
+}
 
-	progress(fmt.Sprintf("待删除账号数: %d", len(names)))
+// deleteAll 备份并删除 candidates；备份目录不可用时不删除任何账号，每个账号记为删除失败并返回错误
+func (s *Service) deleteAll(ctx context.Context, candidates []model.DeleteCandidate, deleteWorkers int, needConfirm bool, in io.Reader, out io.Writer) ([]model.DeleteResult, error) {
+	s.Logger.Info(fmt.Sprintf("待删除账号数: %d", len(candidates)), "candidates", len(candidates))
+	if s.DryRun {
+		return s.dryRun(candidates), nil
+	}
 	if needConfirm {
-		if !cli.ConfirmDelete(in, out, len(names)) {
-			progress("已取消删除。")
-			return nil
+		if !cli.ConfirmDelete(in, out, len(candidates)) {
+			s.Logger.Info("已取消删除。")
+			return nil, nil
+		}
+	}
+
+	var archive *backup.Archive
+	if s.BackupDir != "" {
+		a, err := backup.NewArchive(s.BackupDir, s.Client.BaseURL, time.Now())
+		if err != nil {
+			err = fmt.Errorf("备份目录不可用，已取消删除: %w", err)
+			s.Logger.Error(err.Error(), "backup_dir", s.BackupDir, "candidates", len(candidates))
+			results := make([]model.DeleteResult, 0, len(candidates))
+			for _, c := range candidates {
+				results = append(results, model.DeleteResult{Name: c.Name, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason, Error: err.Error()})
+			}
+			return results, err
 		}
+		archive = a
+		s.Logger.Info(fmt.Sprintf("删除前备份目录: %s", a.Dir), "backup_dir", a.Dir)
 	}
 
 	workers := deleteWorkers
 	if workers < 1 {
 		workers = 1
 	}
-	taskCh := make(chan string)
-	resultCh := make(chan model.DeleteResult, len(names))
+	taskCh := make(chan model.DeleteCandidate)
+	resultCh := make(chan model.DeleteResult, len(candidates))
 	var wg sync.WaitGroup
 
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for name := range taskCh {
-				resultCh <- s.deleteOne(ctx, name)
+			for c := range taskCh {
+				if shutdown.Interrupted(ctx) {
+					continue
+				}
+				r := s.deleteOne(ctx, c, archive)
+				if r.Action == model.ActionSkip {
+					s.Metrics.ObserveDeleteSkipped()
+				} else {
+					s.Metrics.ObserveDelete(r.Deleted)
+				}
+				resultCh <- r
 			}
 		}()
 	}
 
+	stopping := shutdown.Stopping(ctx)
 	go func() {
-		for _, n := range names {
-			taskCh <- n
+	dispatch:
+		for _, c := range candidates {
+			select {
+			case taskCh <- c:
+			case <-stopping:
+				break dispatch
+			}
 		}
 		close(taskCh)
 		wg.Wait()
 		close(resultCh)
 	}()
 
-	results := make([]model.DeleteResult, 0, len(names))
+	results := make([]model.DeleteResult, 0, len(candidates))
 	done := 0
 	nextReport := 100
 	for r := range resultCh {
 		results = append(results, r)
 		done++
-		if done >= nextReport || done == len(names) {
-			progress(fmt.Sprintf("删除进度: %d/%d", done, len(names)))
+		switch {
+		case r.Deleted:
+			s.Logger.Debug(fmt.Sprintf("[已删除] %s", r.Name), resultAttrs(r)...)
+		case r.Action == model.ActionSkip:
+			s.Logger.Info(fmt.Sprintf("[已恢复] %s 复查正常，跳过删除", r.Name), resultAttrs(r)...)
+		}
+		if done >= nextReport || done == len(candidates) {
+			s.Logger.Info(fmt.Sprintf("删除进度: %d/%d", done, len(candidates)), "done", done, "candidates", len(candidates))
 			nextReport += 100
 		}
 	}
 
-	success := 0
+	success, skipped := 0, 0
 	failed := make([]model.DeleteResult, 0)
 	for _, r := range results {
-		if r.Deleted {
+		switch {
+		case r.Deleted:
 			success++
-		} else {
+		case r.Action == model.ActionSkip:
+			skipped++
+		default:
 			failed = append(failed, r)
 		}
 	}
-	progress(fmt.Sprintf("删除完成: 成功=%d，失败=%d", success, len(failed)))
+	if shutdown.Interrupted(ctx) {
+		s.Logger.Warn(fmt.Sprintf("删除已中断: 已处理 %d/%d，其余账号未删除", done, len(candidates)), "done", done, "candidates", len(candidates))
+	}
+	if s.Reverify != nil {
+		s.Logger.Info(fmt.Sprintf("删除完成: 成功=%d，失败=%d，复查恢复跳过=%d", success, len(failed), skipped), "deleted", success, "failed", len(failed), "skipped", skipped)
+	} else {
+		s.Logger.Info(fmt.Sprintf("删除完成: 成功=%d，失败=%d", success, len(failed)), "deleted", success, "failed", len(failed))
+	}
 	for _, r := range failed {
-		progress(fmt.Sprintf("[删除失败] %s | %s", r.Name, r.Error))
+		s.Logger.Warn(fmt.Sprintf("[删除失败] %s | %s", r.Name, r.Error), resultAttrs(r)...)
+	}
+	return results, nil
+}
+
+func (s *Service) dryRun(candidates []model.DeleteCandidate) []model.DeleteResult {
+	results := make([]model.DeleteResult, 0, len(candidates))
+	for _, c := range candidates {
+		s.Logger.Info(fmt.Sprintf("[DRY-RUN] 将删除 %s | account=%s | auth_index=%s | verdict=%s | reason=%s", c.Name, c.Account, c.AuthIndex, c.Verdict, c.Reason),
+			"name", c.Name, "account", c.Account, "auth_index", c.AuthIndex, "verdict", string(c.Verdict), "reason", c.Reason, "dry_run", true)
+		results = append(results, model.DeleteResult{Name: c.Name, DryRun: true, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason})
+	}
+	if s.PlanOutput != "" {
+		if err := output.WriteJSON(s.PlanOutput, candidates); err != nil {
+			s.Logger.Error(fmt.Sprintf("导出删除计划失败: %v", err), "plan_output", s.PlanOutput, "error", err)
+		} else {
+			s.Logger.Info(fmt.Sprintf("已导出删除计划: %s", s.PlanOutput), "plan_output", s.PlanOutput)
+		}
 	}
+	s.Logger.Info(fmt.Sprintf("dry-run 完成: 将删除=%d，未执行任何删除", len(candidates)), "planned", len(candidates), "dry_run", true)
 	return results
 }
 
-func (s *Service) deleteOne(ctx context.Context, name string) model.DeleteResult {
+func (s *Service) deleteOne(ctx context.Context, c model.DeleteCandidate, archive *backup.Archive) model.DeleteResult {
+	name := c.Name
 	if name == "" {
 		return model.DeleteResult{Name: "", Deleted: false, Error: "missing name"}
 	}
+	if s.Reverify != nil {
+		// 距探测（或 output 生成）可能已过去很久，删除前按当前状态复查
+		pr, err := s.Reverify(ctx, c)
+		if err != nil {
+			return model.DeleteResult{Name: name, Deleted: false, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason, Error: "reverify failed: " + err.Error()}
+		}
+		if pr.Verdict == model.VerdictHealthy {
+			return model.DeleteResult{Name: name, Deleted: false, Action: model.ActionSkip, Verdict: pr.Verdict, Reason: "skipped: recovered", StatusCode: statusCode(pr.StatusCode)}
+		}
+	}
+	if archive != nil {
+		// 备份写入成功后才允许删除
+		content, err := s.Client.DownloadAuthFile(ctx, name)
+		if err == nil {
+			err = archive.Write(name, c.Reason, content)
+		}
+		if err != nil {
+			return model.DeleteResult{Name: name, Deleted: false, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason, Error: "backup failed: " + err.Error()}
+		}
+	}
 	status, data, text, err := s.Client.DeleteOne(ctx, name)
 	if err != nil {
-		return model.DeleteResult{Name: name, Deleted: false, Error: err.Error()}
+		return model.DeleteResult{Name: name, Deleted: false, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason, Error: err.Error()}
 	}
-	ok := status == 200 && str(data["status"]) == "ok"
+	ok := isOK(status, data)
 	errText := ""
 	if !ok {
-		if len(text) > 200 {
-			text = text[:200]
-		}
-		errText = "delete failed, response=" + text
+		errText = "delete failed, response=" + truncate(text)
+	}
+	return model.DeleteResult{Name: name, Deleted: ok, Action: model.ActionDelete, Verdict: c.Verdict, Reason: c.Reason, StatusCode: status, Error: errText}
+}
+
+// resultAttrs 单个账号处置结果的结构化字段
+func resultAttrs(r model.DeleteResult) []any {
+	attrs := []any{
+		"name", r.Name,
+		"action", r.Action,
+		"verdict", string(r.Verdict),
+		"deleted", r.Deleted,
+	}
+	if r.StatusCode != 0 {
+		attrs = append(attrs, "status_code", r.StatusCode)
+	}
+	if r.Reason != "" {
+		attrs = append(attrs, "reason", r.Reason)
+	}
+	if r.Error != "" {
+		attrs = append(attrs, "error", r.Error)
+	}
+	return attrs
+}
+
+func statusCode(sc *int) int {
+	if sc == nil {
+		return 0
+	}
+	return *sc
+}
+
+func isOK(status int, data map[string]any) bool {
+	return status == 200 && str(data["status"]) == "ok"
+}
+
+func truncate(text string) string {
+	if len(text) > 200 {
+		return text[:200]
 	}
-	return model.DeleteResult{Name: name, Deleted: ok, StatusCode: status, Error: errText}
+	return text
 }
 
 func str(v any) string {
@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+// Expr 编译后的 --filter 表达式；nil 匹配全部账号。
+//
+// 语法：
+//
+//	expr   = or
+//	or     = and { "||" and }
+//	and    = unary { "&&" unary }
+//	unary  = "!" unary | "(" or ")" | field [ op value ]
+//	op     = "==" | "!=" | "~" | "!~" | "<" | "<=" | ">" | ">="
+//
+// field 为 auth 文件字段名，可用 . 访问嵌套字段（如 metadata.plan）；type 缺失时回落到 typo。
+// 单独的 field 表示字段存在且不为空/false/0。
+// value 可为带引号的字符串、数字、日期（2006-01-02 或 RFC3339）或 /正则/。
+// == / != 不区分大小写；~ / !~ 对字符串做 glob 匹配（* ?），对 /正则/ 做正则匹配；
+// 比较运算在 value 为日期时按时间比较（字段可为 RFC3339 字符串或 Unix 秒/毫秒），两侧都是数字时按数值比较，否则按字符串比较。
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse 编译表达式；空字符串返回 nil
+func Parse(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "多余的 %q", t.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Match 对 nil 返回 true
+func (e *Expr) Match(f model.AuthFile) bool {
+	if e == nil {
+		return true
+	}
+	return e.root.eval(f)
+}
+
+func (e *Expr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+type node interface {
+	eval(f model.AuthFile) bool
+}
+
+type orNode struct{ l, r node }
+
+func (n orNode) eval(f model.AuthFile) bool { return n.l.eval(f) || n.r.eval(f) }
+
+type andNode struct{ l, r node }
+
+func (n andNode) eval(f model.AuthFile) bool { return n.l.eval(f) && n.r.eval(f) }
+
+type notNode struct{ x node }
+
+func (n notNode) eval(f model.AuthFile) bool { return !n.x.eval(f) }
+
+// existsNode 单独的字段名
+type existsNode struct{ field string }
+
+func (n existsNode) eval(f model.AuthFile) bool {
+	v, ok := Lookup(f, n.field)
+	if !ok || v == nil {
+		return false
+	}
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	default:
+		return true
+	}
+}
+
+type cmpNode struct {
+	field string
+	op    string
+	raw   string
+	re    *regexp.Regexp
+	// num / at 为 value 作为数字、日期解析后的结果
+	num    float64
+	isNum  bool
+	at     time.Time
+	isDate bool
+}
+
+func (n cmpNode) eval(f model.AuthFile) bool {
+	v, _ := Lookup(f, n.field)
+	s := toString(v)
+	switch n.op {
+	case "==", "!=":
+		eq := strings.EqualFold(s, n.raw)
+		if fv, ok := toNumber(v); ok && n.isNum {
+			eq = fv == n.num
+		}
+		return eq == (n.op == "==")
+	case "~", "!~":
+		var m bool
+		if n.re != nil {
+			m = n.re.MatchString(s)
+		} else {
+			m, _ = path.Match(strings.ToLower(n.raw), strings.ToLower(s))
+		}
+		return m == (n.op == "~")
+	}
+
+	var c int
+	switch {
+	case n.isDate:
+		t, ok := toTime(v)
+		if !ok {
+			return false
+		}
+		c = t.Compare(n.at)
+	case n.isNum:
+		fv, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+		c = compareFloat(fv, n.num)
+	default:
+		if v == nil {
+			return false
+		}
+		c = strings.Compare(s, n.raw)
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Lookup 按 . 分隔的路径取字段；type 缺失时回落到 typo
+func Lookup(f model.AuthFile, field string) (any, bool) {
+	parts := strings.Split(field, ".")
+	var cur any = map[string]any(f)
+	for i, p := range parts {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		v, ok := m[p]
+		if !ok && i == 0 && p == "type" {
+			v, ok = m["typo"]
+		}
+		if !ok {
+			return nil, false
+		}
+		cur = v
+	}
+	return cur, true
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseDate(s string) (time.Time, bool) {
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, strings.TrimSpace(s)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// toTime 字段可为日期字符串或 Unix 秒/毫秒
+func toTime(v any) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, ok := parseDate(s); ok {
+			return t, true
+		}
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	if n > 1e12 {
+		return time.UnixMilli(int64(n)), true
+	}
+	return time.Unix(int64(n), 0), true
+}
@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"testing"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestExprMatch(t *testing.T) {
+	f := model.AuthFile{
+		"name":       "a.json",
+		"email":      "Alice@Corp.com",
+		"typo":       "codex",
+		"priority":   float64(5),
+		"disabled":   false,
+		"created_at": "2025-12-01T08:00:00Z",
+		"last_used":  float64(1767225600), // 2026-01-01T00:00:00Z
+		"metadata":   map[string]any{"plan": "plus"},
+	}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`email ~ "*@corp.com"`, true},
+		{`email !~ "*@corp.com"`, false},
+		{`email ~ /^alice@/`, true},
+		{`email ~ /^bob@/ || name == "A.JSON"`, true},
+		{`type == codex`, true},
+		{`priority >= 5 && priority < 10`, true},
+		{`priority == 5.0`, true},
+		{`priority > 10`, false},
+		{`created_at < 2026-01-01`, true},
+		{`created_at >= 2025-12-01T09:00:00Z`, false},
+		{`last_used >= 2026-01-01`, true},
+		{`metadata.plan == plus`, true},
+		{`metadata.missing == plus`, false},
+		{`metadata.missing != plus`, true},
+		{`missing < 3`, false},
+		{`disabled`, false},
+		{`!disabled && (email ~ "*@corp.com")`, true},
+		{`email ~ "*@corp.com" && created_at < 2026-01-01`, true},
+		{`email ~ "*@corp.com" && !(priority > 1)`, false},
+	}
+	for _, tc := range cases {
+		e, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if got := e.Match(f); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		`email ~`,
+		`email = "x"`,
+		`(email == x`,
+		`email == "x`,
+		`priority < /re/`,
+		`email ~ /[/`,
+		`&& a`,
+		`a b`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Fatalf("expected error for %q", src)
+		}
+	}
+	e, err := Parse("  ")
+	if err != nil || e != nil || !e.Match(model.AuthFile{}) {
+		t.Fatalf("empty expression should match everything: %v %v", e, err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	n, err := ParseNames("keep-*.json, vip.json,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"keep-1.json": true, "vip.json": true, "other.json": false} {
+		if n.Match(name) != want {
+			t.Fatalf("%s: want %v", name, want)
+		}
+	}
+	var none *Names
+	if none.Match("x") {
+		t.Fatal("nil list should match nothing")
+	}
+	if _, err := ParseNames("bad["); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
@@ -0,0 +1,49 @@
+package filter
+
+import (
+	"path"
+	"strings"
+)
+
+// Names 账号名称列表，支持 glob（* ?）；方法对 nil 安全，nil 不匹配任何名称
+type Names struct {
+	patterns []string
+}
+
+// ParseNames 解析逗号分隔的名称列表；为空时返回 nil
+func ParseNames(s string) (*Names, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return &Names{patterns: patterns}, nil
+}
+
+func (n *Names) Match(name string) bool {
+	if n == nil {
+		return false
+	}
+	for _, p := range n.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Names) String() string {
+	if n == nil {
+		return ""
+	}
+	return strings.Join(n.patterns, ",")
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokRegex
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	toks []token
+	i    int
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("--filter 第 %d 个字符: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+// lex 切分词法单元；比较运算符按最长匹配
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			p.toks = append(p.toks, token{tokAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			p.toks = append(p.toks, token{tokOr, "||", i})
+			i += 2
+		case c == '(':
+			p.toks = append(p.toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			p.toks = append(p.toks, token{tokRParen, ")", i})
+			i++
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "!~") ||
+			strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			p.toks = append(p.toks, token{tokOp, s[i : i+2], i})
+			i += 2
+		case c == '~' || c == '<' || c == '>':
+			p.toks = append(p.toks, token{tokOp, string(c), i})
+			i++
+		case c == '!':
+			p.toks = append(p.toks, token{tokNot, "!", i})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return p.errorf(token{pos: i}, "字符串缺少结束引号")
+			}
+			p.toks = append(p.toks, token{tokString, b.String(), i})
+			i = j + 1
+		case c == '/':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(s) && s[j] != '/'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && s[j+1] == '/' {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return p.errorf(token{pos: i}, "正则缺少结束的 /")
+			}
+			p.toks = append(p.toks, token{tokRegex, b.String(), i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n()!=~<>&|\"'", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return p.errorf(token{pos: i}, "无法识别的字符 %q", c)
+			}
+			p.toks = append(p.toks, token{tokIdent, s[i:j], i})
+			i = j
+		}
+	}
+	p.toks = append(p.toks, token{tokEOF, "", len(s)})
+	return nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNot:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if r := p.next(); r.kind != tokRParen {
+			return nil, p.errorf(r, "缺少 )")
+		}
+		return x, nil
+	case tokIdent:
+		if p.peek().kind != tokOp {
+			return existsNode{field: t.text}, nil
+		}
+		return p.parseCmp(t.text, p.next())
+	case tokEOF:
+		return nil, p.errorf(t, "表达式不完整")
+	default:
+		return nil, p.errorf(t, "此处应为字段名，实际为 %q", t.text)
+	}
+}
+
+func (p *parser) parseCmp(field string, op token) (node, error) {
+	v := p.next()
+	n := cmpNode{field: field, op: op.text, raw: v.text}
+	switch v.kind {
+	case tokRegex:
+		if op.text != "~" && op.text != "!~" {
+			return nil, p.errorf(v, "正则只能用于 ~ / !~")
+		}
+		re, err := regexp.Compile("(?i)" + v.text)
+		if err != nil {
+			return nil, p.errorf(v, "正则不合法: %v", err)
+		}
+		n.re = re
+	case tokIdent, tokString:
+		if f, err := strconv.ParseFloat(v.text, 64); err == nil {
+			n.num, n.isNum = f, true
+		}
+		// 日期仅识别未加引号的字面量，"2026-01-01" 按字符串比较
+		if v.kind == tokIdent {
+			n.at, n.isDate = parseDate(v.text)
+		}
+	default:
+		return nil, p.errorf(v, "%s 之后缺少比较值", op.text)
+	}
+	return n, nil
+}
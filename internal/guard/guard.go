@@ -0,0 +1,131 @@
+package guard
+
+import (
+	"fmt"
+
+	"clean_codex_token/internal/model"
+)
+
+// 触发的规则名称，用于日志、报告与指标标签
+const (
+	RuleRatio   = "max_delete_ratio"
+	RuleCount   = "max_delete"
+	RuleMinKeep = "min_keep"
+	RuleCanary  = "canary"
+)
+
+// Guard 删除前的安全阈值。上游故障时全部探测都可能返回 401，触发任一规则即拒绝本轮全部删除；
+// 方法对 nil 安全，未配置任何规则时直接传 nil
+type Guard struct {
+	// MaxRatio 待处置数 / 匹配数超过该比例时拒绝，0 不限制
+	MaxRatio float64
+	// MaxDelete 待处置数超过该数量时拒绝，0 不限制
+	MaxDelete int
+	// MinKeep 处置后剩余的健康账号数低于该数量时拒绝，0 不限制
+	MinKeep int
+	// Canaries 已知正常的账号（name、account 或 auth_index），任一探测失败即拒绝
+	Canaries []string
+}
+
+// Tripped 拒绝删除的原因
+type Tripped struct {
+	Rule   string
+	Reason string
+}
+
+func (e *Tripped) Error() string {
+	return "安全阈值触发，已拒绝删除: " + e.Reason
+}
+
+// New 校验参数；全部规则都未配置时返回 nil
+func New(maxRatio float64, maxDelete, minKeep int, canaries []string) (*Guard, error) {
+	if maxRatio < 0 || maxRatio > 1 {
+		return nil, fmt.Errorf("--max-delete-ratio 需在 0~1 之间: %v", maxRatio)
+	}
+	if maxDelete < 0 {
+		return nil, fmt.Errorf("--max-delete 不能为负数: %d", maxDelete)
+	}
+	if minKeep < 0 {
+		return nil, fmt.Errorf("--min-keep 不能为负数: %d", minKeep)
+	}
+	if maxRatio == 0 && maxDelete == 0 && minKeep == 0 && len(canaries) == 0 {
+		return nil, nil
+	}
+	return &Guard{MaxRatio: maxRatio, MaxDelete: maxDelete, MinKeep: minKeep, Canaries: canaries}, nil
+}
+
+// ForCanaries 金丝雀改按本轮实际探测的文件（auth_index 或 name）匹配的副本，
+// 避免与金丝雀共享 account 的其他失效文件被当作金丝雀
+func (g *Guard) ForCanaries(keys []string) *Guard {
+	if g == nil || len(g.Canaries) == 0 {
+		return g
+	}
+	c := *g
+	c.Canaries = keys
+	return &c
+}
+
+// Check 在删除前评估全部规则，触发时返回 *Tripped。
+// matched 为匹配的账号数（含待处置账号），healthy 为处置后剩余的健康账号数（见 HealthyLeft），
+// accounts 为全部探测结果；从 output 直接删除时 accounts 为 nil，只检查候选中的金丝雀账号。
+// matched 未知（0）时无法评估 MaxRatio，配置了即拒绝。
+func (g *Guard) Check(matched, healthy int, accounts []model.ProbeResult, candidates []model.DeleteCandidate) error {
+	if g == nil || len(candidates) == 0 {
+		return nil
+	}
+	n := len(candidates)
+	if name := g.failedCanary(accounts, candidates); name != "" {
+		return &Tripped{Rule: RuleCanary, Reason: fmt.Sprintf("金丝雀账号 %s 探测失败，疑似上游故障", name)}
+	}
+	if g.MaxDelete > 0 && n > g.MaxDelete {
+		return &Tripped{Rule: RuleCount, Reason: fmt.Sprintf("待处置 %d 个，超过上限 %d", n, g.MaxDelete)}
+	}
+	if g.MaxRatio > 0 {
+		if matched <= 0 {
+			return &Tripped{Rule: RuleRatio, Reason: "匹配账号数未知，无法评估 --max-delete-ratio"}
+		}
+		if float64(n)/float64(matched) > g.MaxRatio {
+			return &Tripped{Rule: RuleRatio, Reason: fmt.Sprintf("待处置 %d/%d（%.1f%%），超过比例上限 %.1f%%", n, matched, float64(n)*100/float64(matched), g.MaxRatio*100)}
+		}
+	}
+	if g.MinKeep > 0 && healthy < g.MinKeep {
+		return &Tripped{Rule: RuleMinKeep, Reason: fmt.Sprintf("处置后仅剩 %d 个健康账号，低于下限 %d", healthy, g.MinKeep)}
+	}
+	return nil
+}
+
+// HealthyLeft 处置后剩余的健康账号数：探测结论为 healthy 且不在待处置列表中。
+// 额度耗尽、冷却中、异常等账号虽然不处置，也不计入
+func HealthyLeft(accounts []model.ProbeResult, candidates []model.DeleteCandidate) int {
+	drop := make(map[string]struct{}, len(candidates))
+	for _, c := range candidates {
+		drop[c.Name] = struct{}{}
+	}
+	n := 0
+	for _, r := range accounts {
+		if _, ok := drop[r.Name]; !ok && r.Verdict == model.VerdictHealthy {
+			n++
+		}
+	}
+	return n
+}
+
+// failedCanary 返回第一个探测结论不为 healthy 或出现在待处置列表中的金丝雀账号
+func (g *Guard) failedCanary(accounts []model.ProbeResult, candidates []model.DeleteCandidate) string {
+	if len(g.Canaries) == 0 {
+		return ""
+	}
+	for _, c := range g.Canaries {
+		for _, r := range accounts {
+			if (r.Name == c || r.Account == c || r.AuthIndex == c) && r.Verdict != model.VerdictHealthy {
+				return c
+			}
+		}
+		for _, r := range candidates {
+			if r.Name == c || r.Account == c || r.AuthIndex == c {
+				return c
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"clean_codex_token/internal/model"
+)
+
+func candidates(names ...string) []model.DeleteCandidate {
+	out := make([]model.DeleteCandidate, 0, len(names))
+	for _, n := range names {
+		out = append(out, model.DeleteCandidate{Name: n})
+	}
+	return out
+}
+
+func rule(err error) string {
+	var tripped *Tripped
+	if errors.As(err, &tripped) {
+		return tripped.Rule
+	}
+	return ""
+}
+
+func TestNewDisabledAndValidation(t *testing.T) {
+	g, err := New(0, 0, 0, nil)
+	if err != nil || g != nil {
+		t.Fatalf("expected nil guard, got %+v err=%v", g, err)
+	}
+	if err := g.Check(1, 1, nil, candidates("a")); err != nil {
+		t.Fatalf("nil guard should allow: %v", err)
+	}
+	for _, bad := range []struct {
+		ratio        float64
+		max, minKeep int
+	}{{1.5, 0, 0}, {-0.1, 0, 0}, {0, -1, 0}, {0, 0, -1}} {
+		if _, err := New(bad.ratio, bad.max, bad.minKeep, nil); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		g       *Guard
+		matched int
+		healthy int
+		cands   []model.DeleteCandidate
+		want    string
+	}{
+		{"ratio ok", &Guard{MaxRatio: 0.5}, 4, 2, candidates("a", "b"), ""},
+		{"ratio tripped", &Guard{MaxRatio: 0.5}, 4, 1, candidates("a", "b", "c"), RuleRatio},
+		{"count tripped", &Guard{MaxDelete: 2}, 100, 97, candidates("a", "b", "c"), RuleCount},
+		{"min keep tripped", &Guard{MinKeep: 2}, 3, 1, candidates("a", "b"), RuleMinKeep},
+		{"min keep ok", &Guard{MinKeep: 1}, 3, 1, candidates("a", "b"), ""},
+		{"unknown matched refuses ratio", &Guard{MaxRatio: 0.1, MinKeep: 1}, 0, 5, candidates("a"), RuleRatio},
+		{"min keep counts healthy only", &Guard{MinKeep: 5}, 0, 4, candidates("a"), RuleMinKeep},
+		{"unknown matched with count only", &Guard{MaxDelete: 5}, 0, 0, candidates("a"), ""},
+		{"no candidates", &Guard{MaxDelete: 1, MinKeep: 10}, 1, 0, nil, ""},
+		{"canary candidate", &Guard{Canaries: []string{"c@test"}}, 0, 0, []model.DeleteCandidate{{Name: "c", Account: "c@test"}}, RuleCanary},
+	}
+	for _, tc := range cases {
+		if got := rule(tc.g.Check(tc.matched, tc.healthy, nil, tc.cands)); got != tc.want {
+			t.Fatalf("%s: rule=%q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// 10 个匹配账号中 9 个失效、本轮只处置其中 3 个：剩余 7 个里只有 1 个健康
+func TestCheckMinKeepIgnoresUnusableAccounts(t *testing.T) {
+	accounts := []model.ProbeResult{{Name: "ok", Verdict: model.VerdictHealthy}}
+	for _, v := range []model.Verdict{model.VerdictUnauthorized, model.VerdictUnauthorized, model.VerdictUnauthorized,
+		model.VerdictQuotaExhausted, model.VerdictQuotaExhausted, model.VerdictTransportError, model.VerdictUnknown,
+		model.VerdictRateLimited, model.VerdictQuotaExhausted} {
+		accounts = append(accounts, model.ProbeResult{Name: fmt.Sprintf("dead-%d", len(accounts)), Verdict: v})
+	}
+	cands := candidates("dead-1", "dead-2", "dead-3")
+	if n := HealthyLeft(accounts, cands); n != 1 {
+		t.Fatalf("HealthyLeft = %d, want 1", n)
+	}
+	g := &Guard{MinKeep: 2}
+	if got := rule(g.Check(len(accounts), HealthyLeft(accounts, cands), accounts, cands)); got != RuleMinKeep {
+		t.Fatalf("non-candidate failures must not count as kept, got %q", got)
+	}
+}
+
+func TestCheckCanaryVerdict(t *testing.T) {
+	g := &Guard{Canaries: []string{"good"}}
+	accounts := []model.ProbeResult{{Name: "good", Verdict: model.VerdictTransportError}, {Name: "bad", Verdict: model.VerdictUnauthorized}}
+	// 金丝雀本身未进入待处置列表（如 transport-error 被 keep），仍视为失败
+	if got := rule(g.Check(10, 9, accounts, candidates("bad"))); got != RuleCanary {
+		t.Fatalf("expected canary trip, got %q", got)
+	}
+	accounts[0].Verdict = model.VerdictHealthy
+	if err := g.Check(10, 9, accounts, candidates("bad")); err != nil {
+		t.Fatalf("healthy canary should allow: %v", err)
+	}
+}
+
+func TestForCanariesMatchesChosenFiles(t *testing.T) {
+	g := &Guard{Canaries: []string{"b@test"}, MaxDelete: 5}
+	cands := []model.DeleteCandidate{{Name: "b-dup", Account: "b@test", AuthIndex: "idx-d"}}
+	if got := rule(g.Check(10, 9, nil, cands)); got != RuleCanary {
+		t.Fatalf("account match should trip the unscoped guard, got %q", got)
+	}
+	// 本轮实际作为金丝雀探测的是 idx-b，同 account 的 b-dup 可以处置
+	scoped := g.ForCanaries([]string{"idx-b"})
+	if err := scoped.Check(10, 9, nil, cands); err != nil {
+		t.Fatalf("other file sharing the canary account should be deletable: %v", err)
+	}
+	if scoped.MaxDelete != 5 || g.Canaries[0] != "b@test" {
+		t.Fatalf("ForCanaries must copy other rules and leave the guard unchanged: %+v %+v", scoped, g)
+	}
+	var none *Guard
+	if none.ForCanaries([]string{"x"}) != nil {
+		t.Fatal("nil guard should stay nil")
+	}
+}
@@ -0,0 +1,293 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+// MaxEvents 每个账号最多保留的探测事件数
+const MaxEvents = 50
+
+type Event struct {
+	At         time.Time     `json:"at"`
+	Outcome    model.Verdict `json:"outcome"`
+	StatusCode *int          `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type Record struct {
+	Key                 string        `json:"key"`
+	Name                string        `json:"name"`
+	AuthIndex           string        `json:"auth_index"`
+	TotalProbes         int           `json:"total_probes"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	ConsecutiveErrors   int           `json:"consecutive_errors"`
+	FirstSeenAt         time.Time     `json:"first_seen_at"`
+	FirstFailingAt      *time.Time    `json:"first_failing_at,omitempty"`
+	LastOKAt            *time.Time    `json:"last_ok_at,omitempty"`
+	LastProbeAt         time.Time     `json:"last_probe_at"`
+	LastOutcome         model.Verdict `json:"last_outcome"`
+	QuarantinedAt       *time.Time    `json:"quarantined_at,omitempty"`
+	// CoolingUntil 额度耗尽账号等待的额度重置时间，此前不再探测
+	CoolingUntil *time.Time `json:"cooling_until,omitempty"`
+	// CoolingSetAt 设置 CoolingUntil 的时间；设置时已过期的 CoolingUntil 到期后不计为一次重置
+	CoolingSetAt *time.Time `json:"cooling_set_at,omitempty"`
+	// CoolingResets 冷却期间经历的额度重置次数：重置后复探仍耗尽则加一
+	CoolingResets int     `json:"cooling_resets,omitempty"`
+	Disabled      bool    `json:"disabled,omitempty"`
+	Events        []Event `json:"events"`
+}
+
+type fileData struct {
+	Version int       `json:"version"`
+	Records []*Record `json:"records"`
+}
+
+// Store 持久化每个账号的探测历史，跨运行、跨 cron 周期保留连续失败次数等状态
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*Record
+	// touched Stage 得到的副本上被写入过的 key，Commit 时只并入这些记录；非副本为 nil
+	touched map[string]struct{}
+}
+
+// NewMemory 返回不落盘的历史存储
+func NewMemory() *Store {
+	return &Store{records: make(map[string]*Record)}
+}
+
+// Open 从 path 加载历史；文件不存在时返回空存储
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, records: make(map[string]*Record)}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取历史文件失败: %w", err)
+	}
+	var data fileData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("读取历史文件失败: %w", err)
+	}
+	for _, r := range data.Records {
+		if r != nil && r.Key != "" {
+			s.records[r.Key] = r
+		}
+	}
+	return s, nil
+}
+
+func Key(name, authIndex string) string {
+	if authIndex != "" {
+		return authIndex
+	}
+	return name
+}
+
+// Record 记录一次探测结果，返回更新后的记录副本
+func (s *Store) Record(name, authIndex string, ev Event) Record {
+	key := Key(name, authIndex)
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touch(key)
+	r, ok := s.records[key]
+	if !ok {
+		r = &Record{Key: key, FirstSeenAt: ev.At}
+		s.records[key] = r
+	}
+	if name != "" {
+		r.Name = name
+	}
+	r.AuthIndex = authIndex
+	r.TotalProbes++
+	r.LastProbeAt = ev.At
+	r.LastOutcome = ev.Outcome
+
+	if ev.Outcome == model.VerdictHealthy {
+		at := ev.At
+		r.LastOKAt = &at
+		r.FirstFailingAt = nil
+		r.ConsecutiveFailures = 0
+		r.ConsecutiveErrors = 0
+		r.CoolingUntil, r.CoolingSetAt = nil, nil
+		r.CoolingResets = 0
+	} else {
+		if r.ConsecutiveFailures == 0 {
+			at := ev.At
+			r.FirstFailingAt = &at
+		}
+		r.ConsecutiveFailures++
+		if ev.Outcome != model.VerdictQuotaExhausted {
+			r.CoolingUntil, r.CoolingSetAt = nil, nil
+			r.CoolingResets = 0
+		}
+		if ev.Outcome.IsError() {
+			r.ConsecutiveErrors++
+		} else {
+			r.ConsecutiveErrors = 0
+		}
+	}
+
+	r.Events = append(r.Events, ev)
+	if len(r.Events) > MaxEvents {
+		r.Events = append([]Event(nil), r.Events[len(r.Events)-MaxEvents:]...)
+	}
+	return copyRecord(r)
+}
+
+func (s *Store) Get(key string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[key]
+	if !ok {
+		return Record{}, false
+	}
+	return copyRecord(r), true
+}
+
+// Cool 将额度耗尽的账号冷却到 until（额度重置时间）；上一次冷却设置时尚未到期、现在已到期，
+// 说明额度已重置过一次，重置次数加一。返回更新后的记录副本
+func (s *Store) Cool(name, authIndex string, until, now time.Time) Record {
+	key := Key(name, authIndex)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touch(key)
+	r, ok := s.records[key]
+	if !ok {
+		r = &Record{Key: key, Name: name, AuthIndex: authIndex, FirstSeenAt: now}
+		s.records[key] = r
+	}
+	if r.CoolingUntil != nil && r.CoolingSetAt != nil && r.CoolingUntil.After(*r.CoolingSetAt) && !now.Before(*r.CoolingUntil) {
+		r.CoolingResets++
+	}
+	r.CoolingUntil, r.CoolingSetAt = &until, &now
+	return copyRecord(r)
+}
+
+// Quarantine 将账号标记为隔离状态；disabled 表示已通过管理接口禁用
+func (s *Store) Quarantine(name, authIndex string, at time.Time, disabled bool) {
+	key := Key(name, authIndex)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touch(key)
+	r, ok := s.records[key]
+	if !ok {
+		r = &Record{Key: key, Name: name, AuthIndex: authIndex, FirstSeenAt: at}
+		s.records[key] = r
+	}
+	r.QuarantinedAt = &at
+	r.Disabled = disabled
+}
+
+// Release 解除隔离状态
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok {
+		s.touch(key)
+		r.QuarantinedAt = nil
+		r.Disabled = false
+	}
+}
+
+// Quarantined 返回当前处于隔离状态的账号
+func (s *Store) Quarantined() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Record, 0)
+	for _, r := range s.records {
+		if r.QuarantinedAt != nil {
+			out = append(out, copyRecord(r))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Stage 返回当前记录的内存副本：写入只作用于副本，Commit 后才并入 s。
+// 用于结论尚不可信（如金丝雀未复查）的一轮探测
+func (s *Store) Stage() *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	staged := NewMemory()
+	staged.touched = make(map[string]struct{})
+	for k, r := range s.records {
+		c := copyRecord(r)
+		staged.records[k] = &c
+	}
+	return staged
+}
+
+// Commit 将 Stage 得到的副本中写入过的记录并入 s，其余记录保持 s 中的现状（如期间其他操作的隔离/解除隔离）。
+// 写入过的 key 以副本为准（后写者胜）：Stage 之后 s 中对同一 key 的修改会被覆盖
+func (s *Store) Commit(staged *Store) {
+	staged.mu.Lock()
+	defer staged.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range staged.touched {
+		if r, ok := staged.records[k]; ok {
+			c := copyRecord(r)
+			s.records[k] = &c
+			s.touch(k)
+		}
+	}
+}
+
+// touch 记录写入过的 key；调用方持有 s.mu
+func (s *Store) touch(key string) {
+	if s.touched != nil {
+		s.touched[key] = struct{}{}
+	}
+}
+
+// Save 原子写入历史文件；内存存储直接返回
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	data := fileData{Version: 1, Records: make([]*Record, 0, len(s.records))}
+	for _, r := range s.records {
+		data.Records = append(data.Records, r)
+	}
+	sort.Slice(data.Records, func(i, j int) bool { return data.Records[i].Key < data.Records[j].Key })
+	b, err := json.MarshalIndent(data, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("写入历史文件失败: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("写入历史文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("写入历史文件失败: %w", err)
+	}
+	return nil
+}
+
+func copyRecord(r *Record) Record {
+	c := *r
+	c.Events = append([]Event(nil), r.Events...)
+	return c
+}
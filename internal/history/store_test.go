@@ -0,0 +1,128 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestStorePersistsAcrossOpen(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "history.json")
+	s, err := Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Record("a", "idx-a", Event{At: t0, Outcome: model.VerdictHealthy})
+	s.Record("a", "idx-a", Event{At: t0.Add(time.Minute), Outcome: model.VerdictTransportError})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := s2.Record("a", "idx-a", Event{At: t0.Add(2 * time.Minute), Outcome: model.VerdictTransportError})
+	if rec.ConsecutiveErrors != 2 || rec.ConsecutiveFailures != 2 || rec.TotalProbes != 3 {
+		t.Fatalf("unexpected counters: %+v", rec)
+	}
+	if rec.LastOKAt == nil || !rec.LastOKAt.Equal(t0) {
+		t.Fatalf("unexpected last_ok_at: %v", rec.LastOKAt)
+	}
+	if rec.FirstFailingAt == nil || !rec.FirstFailingAt.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("unexpected first_failing_at: %v", rec.FirstFailingAt)
+	}
+
+	rec = s2.Record("a", "idx-a", Event{At: t0.Add(3 * time.Minute), Outcome: model.VerdictHealthy})
+	if rec.ConsecutiveFailures != 0 || rec.FirstFailingAt != nil {
+		t.Fatalf("ok outcome should reset failures: %+v", rec)
+	}
+}
+
+func TestStoreCoolingCountsResets(t *testing.T) {
+	s := NewMemory()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reset := t0.Add(5 * time.Hour)
+	s.Record("a", "idx-a", Event{At: t0, Outcome: model.VerdictQuotaExhausted})
+	if rec := s.Cool("a", "idx-a", reset, t0); rec.CoolingResets != 0 || !rec.CoolingUntil.Equal(reset) {
+		t.Fatalf("first cooling: %+v", rec)
+	}
+	// 冷却期内再次耗尽不算重置
+	if rec := s.Cool("a", "idx-a", reset, t0.Add(time.Hour)); rec.CoolingResets != 0 {
+		t.Fatalf("cooling within window: %+v", rec)
+	}
+	next := reset.Add(5 * time.Hour)
+	s.Record("a", "idx-a", Event{At: reset.Add(time.Minute), Outcome: model.VerdictQuotaExhausted})
+	if rec := s.Cool("a", "idx-a", next, reset.Add(time.Minute)); rec.CoolingResets != 1 || !rec.CoolingUntil.Equal(next) {
+		t.Fatalf("cooling after reset: %+v", rec)
+	}
+
+	rec := s.Record("a", "idx-a", Event{At: next.Add(time.Minute), Outcome: model.VerdictHealthy})
+	if rec.CoolingUntil != nil || rec.CoolingResets != 0 {
+		t.Fatalf("healthy outcome should clear cooling: %+v", rec)
+	}
+}
+
+func TestStoreCoolingIgnoresStaleDeadline(t *testing.T) {
+	s := NewMemory()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Record("a", "idx-a", Event{At: t0, Outcome: model.VerdictQuotaExhausted})
+	// 设置时已过期的截止时间（上游 reset_at 过期）不算一次重置
+	s.Cool("a", "idx-a", t0.Add(-time.Hour), t0)
+	s.Record("a", "idx-a", Event{At: t0.Add(time.Minute), Outcome: model.VerdictQuotaExhausted})
+	if rec := s.Cool("a", "idx-a", t0.Add(-time.Hour), t0.Add(time.Minute)); rec.CoolingResets != 0 {
+		t.Fatalf("stale deadline counted as reset: %+v", rec)
+	}
+}
+
+func TestStoreStageCommit(t *testing.T) {
+	s := NewMemory()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Record("a", "idx-a", Event{At: t0, Outcome: model.VerdictUnauthorized})
+
+	staged := s.Stage()
+	staged.Record("a", "idx-a", Event{At: t0.Add(time.Minute), Outcome: model.VerdictUnauthorized})
+	staged.Record("b", "idx-b", Event{At: t0.Add(time.Minute), Outcome: model.VerdictUnauthorized})
+	if rec, _ := s.Get("idx-a"); rec.ConsecutiveFailures != 1 {
+		t.Fatalf("staged writes must not touch the store: %+v", rec)
+	}
+	if _, ok := s.Get("idx-b"); ok {
+		t.Fatal("staged record leaked into the store")
+	}
+
+	s.Commit(staged)
+	if rec, _ := s.Get("idx-a"); rec.ConsecutiveFailures != 2 {
+		t.Fatalf("commit should apply staged counters: %+v", rec)
+	}
+	if _, ok := s.Get("idx-b"); !ok {
+		t.Fatal("commit should add staged records")
+	}
+}
+
+func TestStoreCommitKeepsConcurrentChanges(t *testing.T) {
+	s := NewMemory()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Record("a", "idx-a", Event{At: t0, Outcome: model.VerdictUnauthorized})
+	s.Record("c", "idx-c", Event{At: t0, Outcome: model.VerdictUnauthorized})
+	s.Quarantine("c", "idx-c", t0, true)
+
+	staged := s.Stage()
+	staged.Record("a", "idx-a", Event{At: t0.Add(time.Minute), Outcome: model.VerdictUnauthorized})
+	// Stage 之后其他操作对未被探测的账号做的隔离/解除隔离
+	s.Quarantine("d", "idx-d", t0.Add(time.Minute), false)
+	s.Release("idx-c")
+
+	s.Commit(staged)
+	if rec, _ := s.Get("idx-a"); rec.ConsecutiveFailures != 2 {
+		t.Fatalf("commit should apply staged counters: %+v", rec)
+	}
+	if rec, ok := s.Get("idx-d"); !ok || rec.QuarantinedAt == nil {
+		t.Fatalf("commit reverted a quarantine made after Stage: %+v", rec)
+	}
+	if rec, _ := s.Get("idx-c"); rec.QuarantinedAt != nil {
+		t.Fatalf("commit reverted a release made after Stage: %+v", rec)
+	}
+}
@@ -0,0 +1,270 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"clean_codex_token/internal/filter"
+	"clean_codex_token/internal/mgmt"
+	"clean_codex_token/internal/model"
+)
+
+// list 的输出格式
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// DefaultColumns 未指定 --columns 时的列
+const DefaultColumns = "name,account,type,provider,auth_index,disabled"
+
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", FormatTable:
+		return FormatTable, nil
+	case FormatJSON, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("未知 list 格式: %q（可选 table / json / csv）", s)
+	}
+}
+
+// Row 一个账号；Target 为多目标模式下的目标名称
+type Row struct {
+	Target string
+	File   model.AuthFile
+}
+
+// Column 一列输出
+type Column struct {
+	Name string
+	get  func(r Row) any
+}
+
+func (c Column) Value(r Row) any { return c.get(r) }
+
+// builtin 内置列，处理字段别名；其他列名按 auth 文件字段（可用 . 访问嵌套字段）原样取值
+var builtin = map[string]func(r Row) any{
+	"target": func(r Row) any { return r.Target },
+	"name": func(r Row) any {
+		return firstString(r.File, "name", "id")
+	},
+	"account": func(r Row) any {
+		return firstString(r.File, "account", "email")
+	},
+	"type": func(r Row) any {
+		return mgmt.GetItemType(r.File)
+	},
+	"chatgpt_account_id": func(r Row) any {
+		return mgmt.ExtractChatgptAccountID(r.File)
+	},
+	"disabled": func(r Row) any {
+		v, _ := r.File["disabled"].(bool)
+		return v
+	},
+}
+
+var aliases = map[string]string{"email": "account", "typo": "type", "id": "name"}
+
+// ParseColumns 解析逗号分隔的列名；空字符串返回 DefaultColumns
+func ParseColumns(spec string) []Column {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultColumns
+	}
+	var cols []Column
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if a, ok := aliases[key]; ok {
+			key = a
+		}
+		if get, ok := builtin[key]; ok {
+			cols = append(cols, Column{Name: key, get: get})
+			continue
+		}
+		field := name
+		cols = append(cols, Column{Name: name, get: func(r Row) any {
+			v, _ := filter.Lookup(r.File, field)
+			return v
+		}})
+	}
+	return cols
+}
+
+func firstString(f model.AuthFile, keys ...string) string {
+	for _, k := range keys {
+		if v, _ := f[k].(string); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Sort 按目标、名称排序
+func Sort(rows []Row) {
+	name := builtin["name"]
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Target != rows[j].Target {
+			return rows[i].Target < rows[j].Target
+		}
+		return name(rows[i]).(string) < name(rows[j]).(string)
+	})
+}
+
+// Write 按 format 输出账号清单；json 保留字段原始类型，table / csv 转为字符串
+func Write(w io.Writer, format string, cols []Column, rows []Row) error {
+	switch format {
+	case FormatJSON:
+		items := make([]map[string]any, 0, len(rows))
+		for _, r := range rows {
+			item := make(map[string]any, len(cols))
+			for _, c := range cols {
+				item[c.Name] = c.Value(r)
+			}
+			items = append(items, item)
+		}
+		return encodeJSON(w, items)
+	default:
+		records := make([][]string, 0, len(rows))
+		for _, r := range rows {
+			rec := make([]string, len(cols))
+			for i, c := range cols {
+				rec[i] = cell(c.Value(r))
+			}
+			records = append(records, rec)
+		}
+		return writeTable(w, format, names(cols), records)
+	}
+}
+
+// Group 一组账号的数量
+type Group struct {
+	Keys  []string
+	Count int
+}
+
+// GroupBy 按 cols 的取值分组计数，按数量降序、取值升序排列
+func GroupBy(rows []Row, cols []Column) []Group {
+	idx := make(map[string]int)
+	var groups []Group
+	for _, r := range rows {
+		keys := make([]string, len(cols))
+		for i, c := range cols {
+			keys[i] = cell(c.Value(r))
+		}
+		k := strings.Join(keys, "\x00")
+		if i, ok := idx[k]; ok {
+			groups[i].Count++
+			continue
+		}
+		idx[k] = len(groups)
+		groups = append(groups, Group{Keys: keys, Count: 1})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return strings.Join(groups[i].Keys, "\x00") < strings.Join(groups[j].Keys, "\x00")
+	})
+	return groups
+}
+
+// WriteGroups 输出分组计数；table 格式末尾附合计行
+func WriteGroups(w io.Writer, format string, cols []Column, groups []Group) error {
+	switch format {
+	case FormatJSON:
+		items := make([]map[string]any, 0, len(groups))
+		for _, g := range groups {
+			item := make(map[string]any, len(cols)+1)
+			for i, c := range cols {
+				item[c.Name] = g.Keys[i]
+			}
+			item["count"] = g.Count
+			items = append(items, item)
+		}
+		return encodeJSON(w, items)
+	default:
+		total := 0
+		records := make([][]string, 0, len(groups))
+		for _, g := range groups {
+			records = append(records, append(append([]string{}, g.Keys...), strconv.Itoa(g.Count)))
+			total += g.Count
+		}
+		if err := writeTable(w, format, append(names(cols), "count"), records); err != nil || format != FormatTable {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "合计: %d 个账号，%d 组\n", total, len(groups))
+		return err
+	}
+}
+
+func names(cols []Column) []string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = c.Name
+	}
+	return out
+}
+
+func writeTable(w io.Writer, format string, header []string, records [][]string) error {
+	if format == FormatCSV {
+		cw := csv.NewWriter(w)
+		_ = cw.Write(header)
+		_ = cw.WriteAll(records)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	upper := make([]string, len(header))
+	for i, h := range header {
+		upper[i] = strings.ToUpper(h)
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	for _, rec := range records {
+		cells := make([]string, len(rec))
+		for i, c := range rec {
+			cells[i] = c
+			if c == "" {
+				cells[i] = "-"
+			}
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// cell 把字段值转为单元格文本；对象、数组按 JSON 输出
+func cell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(b)
+	}
+}
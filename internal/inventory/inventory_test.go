@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"clean_codex_token/internal/model"
+)
+
+func sampleRows() []Row {
+	return []Row{
+		{File: model.AuthFile{"name": "b", "email": "b@x", "typo": "codex", "provider": "openai", "metadata": map[string]any{"plan": "plus"}}},
+		{File: model.AuthFile{"name": "a", "account": "a@x", "type": "codex", "provider": "openai", "disabled": true, "chatgpt_account_id": "acc-a"}},
+		{File: model.AuthFile{"id": "c", "type": "claude", "provider": "anthropic"}},
+	}
+}
+
+func TestParseColumnsAliasesAndFields(t *testing.T) {
+	cols := ParseColumns("email, typo ,chatgpt_account_id,metadata.plan")
+	got := []string{}
+	for _, c := range cols {
+		got = append(got, c.Name)
+	}
+	if strings.Join(got, ",") != "account,type,chatgpt_account_id,metadata.plan" {
+		t.Fatalf("unexpected columns: %v", got)
+	}
+	rows := sampleRows()
+	if v := cols[0].Value(rows[0]); v != "b@x" {
+		t.Fatalf("email alias: %v", v)
+	}
+	if v := cols[1].Value(rows[0]); v != "codex" {
+		t.Fatalf("typo fallback: %v", v)
+	}
+	if v := cols[2].Value(rows[1]); v != "acc-a" {
+		t.Fatalf("chatgpt_account_id: %v", v)
+	}
+	if v := cols[3].Value(rows[0]); v != "plus" {
+		t.Fatalf("nested field: %v", v)
+	}
+	if len(ParseColumns("")) != len(strings.Split(DefaultColumns, ",")) {
+		t.Fatal("empty spec should use default columns")
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	rows := sampleRows()
+	Sort(rows)
+	cols := ParseColumns("name,disabled")
+
+	var b bytes.Buffer
+	if err := Write(&b, FormatJSON, cols, rows); err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(b.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0]["name"] != "a" || items[0]["disabled"] != true {
+		t.Fatalf("unexpected json: %s", b.String())
+	}
+
+	b.Reset()
+	if err := Write(&b, FormatCSV, cols, rows); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "name,disabled\na,true\nb,false\nc,false\n" {
+		t.Fatalf("unexpected csv: %q", b.String())
+	}
+
+	b.Reset()
+	if err := Write(&b, FormatTable, ParseColumns("name,provider,missing"), rows); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[0], "NAME") || !strings.HasSuffix(lines[1], "-") {
+		t.Fatalf("unexpected table:\n%s", b.String())
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	cols := ParseColumns("type,provider")
+	groups := GroupBy(sampleRows(), cols)
+	if len(groups) != 2 || groups[0].Count != 2 || strings.Join(groups[0].Keys, "/") != "codex/openai" {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+
+	var b bytes.Buffer
+	if err := WriteGroups(&b, FormatJSON, cols, groups); err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(b.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	if items[1]["type"] != "claude" || items[1]["count"] != float64(1) {
+		t.Fatalf("unexpected group json: %s", b.String())
+	}
+
+	b.Reset()
+	if err := WriteGroups(&b, FormatTable, cols, groups); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "合计: 3 个账号，2 组") {
+		t.Fatalf("missing total:\n%s", b.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatTable {
+		t.Fatalf("default format: %q %v", f, err)
+	}
+	if _, err := ParseFormat("markdown"); err == nil {
+		t.Fatal("expected error")
+	}
+}
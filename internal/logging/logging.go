@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// 日志格式：console 仅输出消息文本（适合人工查看），text/json 为 slog 结构化格式（适合日志采集）
+const (
+	FormatConsole = "console"
+	FormatText    = "text"
+	FormatJSON    = "json"
+)
+
+// New 按格式与级别创建写入 w 的 logger
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatConsole:
+		return slog.New(&consoleHandler{mu: &sync.Mutex{}, w: w, level: lvl}), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("未知日志格式: %q（可选 console / text / json）", format)
+	}
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("未知日志级别: %q（可选 debug / info / warn / error）", s)
+	}
+}
+
+// Discard 丢弃全部日志，作为各 Service 的默认值
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// consoleHandler 每条日志只输出一行消息文本，结构化字段仅用于 text/json 格式；
+// 例外是 target 字段（多目标模式），以 "[target] " 前缀输出以区分并发目标
+type consoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Level
+	prefix string
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, h.prefix+r.Message)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for _, a := range attrs {
+		if a.Key == "target" && a.Value.String() != "" {
+			c := *h
+			c.prefix = "[" + a.Value.String() + "] "
+			return &c
+		}
+	}
+	return h
+}
+
+func (h *consoleHandler) WithGroup(string) slog.Handler { return h }
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConsoleWritesMessageOnly(t *testing.T) {
+	var sb strings.Builder
+	l, err := New(&sb, FormatConsole, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("hidden")
+	l.Info("总账号数: 3", "total", 3)
+	if sb.String() != "总账号数: 3\n" {
+		t.Fatalf("unexpected console output: %q", sb.String())
+	}
+}
+
+func TestConsolePrefixesTarget(t *testing.T) {
+	var sb strings.Builder
+	l, _ := New(&sb, FormatConsole, "info")
+	l.With("target", "prod-a").With("run", "x").Info("探测完成")
+	if sb.String() != "[prod-a] 探测完成\n" {
+		t.Fatalf("unexpected console output: %q", sb.String())
+	}
+}
+
+func TestJSONCarriesAttrs(t *testing.T) {
+	var sb strings.Builder
+	l, err := New(&sb, FormatJSON, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("探测结果", "name", "a.json", "verdict", "healthy", "latency_ms", 12)
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(sb.String()), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "DEBUG" || rec["name"] != "a.json" || rec["verdict"] != "healthy" || rec["latency_ms"] != float64(12) {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+}
+
+func TestNewRejectsUnknown(t *testing.T) {
+	if _, err := New(&strings.Builder{}, "xml", "info"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+	if _, err := New(&strings.Builder{}, "json", "trace"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
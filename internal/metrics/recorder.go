@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Recorder 本工具的业务指标；所有方法对 nil 安全，未开启 --metrics-addr 时直接传 nil
+type Recorder struct {
+	Registry *Registry
+	// target 多目标模式下的目标名称，作为所有指标的 target 标签
+	target string
+
+	accountsTotal   *Family
+	accountsMatched *Family
+	verdicts        *Family
+	lastRunVerdicts *Family
+	probeLatency    *Family
+	mgmtErrors      *Family
+	deletions       *Family
+	lastRunTime     *Family
+	lastRunDuration *Family
+	lastRunSuccess  *Family
+	guardTrips      *Family
+	poolHeadroom    *Family
+	poolUsedPercent *Family
+	poolNearLimit   *Family
+}
+
+func NewRecorder() *Recorder {
+	reg := NewRegistry()
+	return &Recorder{
+		Registry:        reg,
+		accountsTotal:   reg.Gauge("clean_codex_accounts_total", "Auth files returned by the management server in the last run.", "target"),
+		accountsMatched: reg.Gauge("clean_codex_accounts_matched", "Auth files matching the probe filters in the last run.", "target"),
+		verdicts:        reg.Counter("clean_codex_probe_verdicts_total", "Probe verdicts by type and provider.", "target", "verdict", "type", "provider"),
+		lastRunVerdicts: reg.Gauge("clean_codex_last_run_verdicts", "Probe verdict counts of the last run.", "target", "verdict"),
+		probeLatency:    reg.Histogram("clean_codex_probe_duration_seconds", "Per-account probe latency including retries.", []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}, "target", "provider"),
+		mgmtErrors:      reg.Counter("clean_codex_mgmt_api_errors_total", "Failed management API calls by operation.", "target", "op"),
+		deletions:       reg.Counter("clean_codex_deletions_total", "Delete attempts by result.", "target", "result"),
+		lastRunTime:     reg.Gauge("clean_codex_last_run_timestamp_seconds", "Unix time when the last run finished.", "target"),
+		lastRunDuration: reg.Gauge("clean_codex_last_run_duration_seconds", "Duration of the last run.", "target"),
+		lastRunSuccess:  reg.Gauge("clean_codex_last_run_success", "Whether the last run finished without error (1/0).", "target"),
+		guardTrips:      reg.Counter("clean_codex_guard_trips_total", "Runs whose deletions were refused by a safety guard, by rule.", "target", "rule"),
+		poolHeadroom:    reg.Gauge("clean_codex_pool_headroom_accounts", "Remaining quota of healthy accounts in the last run, in whole-account equivalents.", "target"),
+		poolUsedPercent: reg.Gauge("clean_codex_pool_used_percent_avg", "Average used_percent of healthy accounts with usage data in the last run.", "target"),
+		poolNearLimit:   reg.Gauge("clean_codex_pool_near_limit_accounts", "Healthy accounts at or above 90% usage in the last run.", "target"),
+	}
+}
+
+// WithTarget 返回共享同一注册表、以 target 标签区分的 Recorder
+func (r *Recorder) WithTarget(name string) *Recorder {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.target = name
+	return &c
+}
+
+func (r *Recorder) SetAccounts(total, matched int) {
+	if r == nil {
+		return
+	}
+	r.accountsTotal.Set(float64(total), r.target)
+	r.accountsMatched.Set(float64(matched), r.target)
+}
+
+func (r *Recorder) ObserveProbe(verdict, typ, provider string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.verdicts.Inc(r.target, verdict, typ, provider)
+	r.probeLatency.Observe(d.Seconds(), r.target, provider)
+}
+
+// SetRunVerdicts 记录本轮各结论数量
+func (r *Recorder) SetRunVerdicts(counts map[string]int) {
+	if r == nil {
+		return
+	}
+	for v, n := range counts {
+		r.lastRunVerdicts.Set(float64(n), r.target, v)
+	}
+}
+
+// SetCapacity 记录本轮健康账号的额度概况
+func (r *Recorder) SetCapacity(headroom, avgUsedPercent float64, nearLimit int) {
+	if r == nil {
+		return
+	}
+	r.poolHeadroom.Set(headroom, r.target)
+	r.poolUsedPercent.Set(avgUsedPercent, r.target)
+	r.poolNearLimit.Set(float64(nearLimit), r.target)
+}
+
+func (r *Recorder) MgmtError(op string) {
+	if r == nil {
+		return
+	}
+	r.mgmtErrors.Inc(r.target, op)
+}
+
+func (r *Recorder) ObserveDelete(ok bool) {
+	if r == nil {
+		return
+	}
+	if ok {
+		r.deletions.Inc(r.target, "success")
+	} else {
+		r.deletions.Inc(r.target, "failure")
+	}
+}
+
+// ObserveDeleteSkipped 记录一次删除前复查已恢复、跳过的删除
+func (r *Recorder) ObserveDeleteSkipped() {
+	if r == nil {
+		return
+	}
+	r.deletions.Inc(r.target, "skipped")
+}
+
+// GuardTrip 记录一次被安全阈值拒绝的删除
+func (r *Recorder) GuardTrip(rule string) {
+	if r == nil {
+		return
+	}
+	r.guardTrips.Inc(r.target, rule)
+}
+
+func (r *Recorder) ObserveRun(finished time.Time, d time.Duration, ok bool) {
+	if r == nil {
+		return
+	}
+	r.lastRunTime.Set(float64(finished.Unix()), r.target)
+	r.lastRunDuration.Set(d.Seconds(), r.target)
+	if ok {
+		r.lastRunSuccess.Set(1, r.target)
+	} else {
+		r.lastRunSuccess.Set(0, r.target)
+	}
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Registry.WriteText(w)
+}
+
+// Serve 在 addr 上暴露 /metrics，ctx 结束后关闭；监听失败立即返回错误
+func Serve(ctx context.Context, addr string, r *Recorder) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go srv.Serve(ln)
+	return ln.Addr(), nil
+}
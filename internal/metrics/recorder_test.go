@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderTextFormat(t *testing.T) {
+	r := NewRecorder()
+	r.SetAccounts(10, 4)
+	r.ObserveProbe("healthy", "codex", "openai", 300*time.Millisecond)
+	r.ObserveProbe("unauthorized", "codex", "openai", 2*time.Second)
+	r.MgmtError("api-call")
+	r.ObserveDelete(true)
+	r.ObserveDelete(false)
+	r.ObserveRun(time.Unix(1700000000, 0), 90*time.Second, true)
+
+	var sb strings.Builder
+	if err := r.Registry.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	text := sb.String()
+	for _, want := range []string{
+		"# TYPE clean_codex_accounts_total gauge\nclean_codex_accounts_total{target=\"\"} 10\n",
+		`clean_codex_accounts_matched{target=""} 4`,
+		`clean_codex_probe_verdicts_total{target="",verdict="unauthorized",type="codex",provider="openai"} 1`,
+		`clean_codex_probe_duration_seconds_bucket{target="",provider="openai",le="0.5"} 1`,
+		`clean_codex_probe_duration_seconds_bucket{target="",provider="openai",le="2.5"} 2`,
+		`clean_codex_probe_duration_seconds_bucket{target="",provider="openai",le="+Inf"} 2`,
+		`clean_codex_probe_duration_seconds_count{target="",provider="openai"} 2`,
+		`clean_codex_mgmt_api_errors_total{target="",op="api-call"} 1`,
+		`clean_codex_deletions_total{target="",result="failure"} 1`,
+		`clean_codex_deletions_total{target="",result="success"} 1`,
+		`clean_codex_last_run_timestamp_seconds{target=""} 1.7e+09`,
+		`clean_codex_last_run_duration_seconds{target=""} 90`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+}
+
+func TestWithTargetSharesRegistry(t *testing.T) {
+	r := NewRecorder()
+	r.WithTarget("prod-a").SetAccounts(5, 5)
+	r.WithTarget("prod-b").SetAccounts(7, 6)
+	var sb strings.Builder
+	_ = r.Registry.WriteText(&sb)
+	for _, want := range []string{`clean_codex_accounts_total{target="prod-a"} 5`, `clean_codex_accounts_matched{target="prod-b"} 6`} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestNilRecorderIsNoop(t *testing.T) {
+	var r *Recorder
+	r.SetAccounts(1, 1)
+	r.ObserveProbe("healthy", "codex", "", time.Second)
+	r.MgmtError("delete")
+	r.ObserveDelete(true)
+	r.ObserveRun(time.Now(), time.Second, false)
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRecorder()
+	r.SetAccounts(3, 3)
+	addr, err := Serve(ctx, "127.0.0.1:0", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || !strings.Contains(string(body), `clean_codex_accounts_total{target=""} 3`) {
+		t.Fatalf("unexpected response: %s %s", resp.Header.Get("Content-Type"), body)
+	}
+}
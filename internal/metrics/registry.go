@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Registry 极简的 Prometheus 指标注册表，按文本格式（0.0.4）输出
+type Registry struct {
+	mu       sync.Mutex
+	families []*Family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type Family struct {
+	reg     *Registry
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Family {
+	return r.register(name, help, kindCounter, labels, nil)
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *Family {
+	return r.register(name, help, kindGauge, labels, nil)
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Family {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return r.register(name, help, kindHistogram, labels, b)
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *Family {
+	f := &Family{reg: r, name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.mu.Lock()
+	r.families = append(r.families, f)
+	r.mu.Unlock()
+	return f
+}
+
+// get 调用方需持有 reg.mu
+func (f *Family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s 需要 %d 个标签值，实际 %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *Family) Add(v float64, labelValues ...string) {
+	f.reg.mu.Lock()
+	defer f.reg.mu.Unlock()
+	f.get(labelValues).value += v
+}
+
+func (f *Family) Inc(labelValues ...string) {
+	f.Add(1, labelValues...)
+}
+
+func (f *Family) Set(v float64, labelValues ...string) {
+	f.reg.mu.Lock()
+	defer f.reg.mu.Unlock()
+	f.get(labelValues).value = v
+}
+
+func (f *Family) Observe(v float64, labelValues ...string) {
+	f.reg.mu.Lock()
+	defer f.reg.mu.Unlock()
+	s := f.get(labelValues)
+	for i, b := range f.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sb strings.Builder
+	for _, f := range r.families {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.series[k]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(&sb, "%s%s %s\n", f.name, labelString(f.labels, s.labelValues, "", ""), formatFloat(s.value))
+				continue
+			}
+			for i, b := range f.buckets {
+				fmt.Fprintf(&sb, "%s_bucket%s %d\n", f.name, labelString(f.labels, s.labelValues, "le", formatFloat(b)), s.counts[i])
+			}
+			fmt.Fprintf(&sb, "%s_bucket%s %d\n", f.name, labelString(f.labels, s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(&sb, "%s_sum%s %s\n", f.name, labelString(f.labels, s.labelValues, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(&sb, "%s_count%s %d\n", f.name, labelString(f.labels, s.labelValues, "", ""), s.count)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func labelString(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, n, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
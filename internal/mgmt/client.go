@@ -18,6 +18,8 @@ type Client struct {
 	HTTPClient *http.Client
 	BaseURL    string
 	Token      string
+	// OnError 管理接口调用失败（网络错误或 HTTP >= 400）时回调，op 为接口名，用于指标统计
+	OnError func(op string)
 }
 
 func NewClient(baseURL, token string, timeoutSec int) *Client {
@@ -32,6 +34,12 @@ func NewClient(baseURL, token string, timeoutSec int) *Client {
 	}
 }
 
+func (c *Client) fail(op string) {
+	if c.OnError != nil {
+		c.OnError(op)
+	}
+}
+
 func safeJSONBytes(b []byte) map[string]any {
 	var out map[string]any
 	if err := json.Unmarshal(b, &out); err != nil {
@@ -47,10 +55,12 @@ func (c *Client) FetchAuthFiles(ctx context.Context) ([]model.AuthFile, error) {
 	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
+		c.fail("auth-files")
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
+		c.fail("auth-files")
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("management auth-files http %d: %s", resp.StatusCode, string(body))
 	}
@@ -75,16 +85,23 @@ func (c *Client) ProbeOne(ctx context.Context, payload map[string]any) (int, map
 	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
+		c.fail("api-call")
 		return 0, nil, err
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	text := string(body)
 	if resp.StatusCode >= 400 {
+		c.fail("api-call")
 		if len(text) > 200 {
 			text = text[:200]
 		}
-		return resp.StatusCode, nil, fmt.Errorf("management api-call http %d: %s", resp.StatusCode, text)
+		return resp.StatusCode, nil, &HTTPError{
+			Op:         "api-call",
+			StatusCode: resp.StatusCode,
+			Body:       text,
+			RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 	return resp.StatusCode, safeJSONBytes(body), nil
 }
@@ -100,9 +117,93 @@ func (c *Client) DeleteOne(ctx context.Context, name string) (int, map[string]an
 	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
+		c.fail("delete")
 		return 0, nil, "", err
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.fail("delete")
+	}
+	return resp.StatusCode, safeJSONBytes(body), string(body), nil
+}
+
+func (c *Client) DownloadAuthFile(ctx context.Context, name string) ([]byte, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	u := c.BaseURL + "/v0/management/auth-files/download?name=" + url.QueryEscape(name)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	for k, v := range MgmtHeaders(c.Token) {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		c.fail("download")
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.fail("download")
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		c.fail("download")
+		text := string(body)
+		if len(text) > 200 {
+			text = text[:200]
+		}
+		return nil, fmt.Errorf("management download http %d: %s", resp.StatusCode, text)
+	}
+	return body, nil
+}
+
+func (c *Client) UploadAuthFile(ctx context.Context, name string, content []byte) (int, map[string]any, string, error) {
+	if name == "" {
+		return 0, nil, "", fmt.Errorf("missing name")
+	}
+	u := c.BaseURL + "/v0/management/auth-files?name=" + url.QueryEscape(name)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(content))
+	headers := MgmtHeaders(c.Token)
+	headers["Content-Type"] = "application/json"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		c.fail("upload")
+		return 0, nil, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.fail("upload")
+	}
+	return resp.StatusCode, safeJSONBytes(body), string(body), nil
+}
+
+// SetDisabled 通过管理接口启用/禁用 auth 文件
+func (c *Client) SetDisabled(ctx context.Context, name string, disabled bool) (int, map[string]any, string, error) {
+	if name == "" {
+		return 0, nil, "", fmt.Errorf("missing name")
+	}
+	b, _ := json.Marshal(map[string]any{"name": name, "disabled": disabled})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPatch, c.BaseURL+"/v0/management/auth-files/status", bytes.NewReader(b))
+	headers := MgmtHeaders(c.Token)
+	headers["Content-Type"] = "application/json"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		c.fail("status")
+		return 0, nil, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.fail("status")
+	}
 	return resp.StatusCode, safeJSONBytes(body), string(body), nil
 }
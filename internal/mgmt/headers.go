@@ -36,10 +36,19 @@ func BuildProbePayload(authIndex, userAgent, chatgptAccountID string) map[string
 		callHeader["Chatgpt-Account-Id"] = chatgptAccountID
 	}
 
-	return map[string]any{
+	return BuildAPICallPayload(authIndex, "GET", "https://chatgpt.com/backend-api/wham/usage", callHeader, "")
+}
+
+// BuildAPICallPayload 构造 /v0/management/api-call 请求体；header 中的 $TOKEN$ 由管理服务替换为账号凭据
+func BuildAPICallPayload(authIndex, method, url string, header map[string]any, data string) map[string]any {
+	payload := map[string]any{
 		"authIndex": authIndex,
-		"method":    "GET",
-		"url":       "https://chatgpt.com/backend-api/wham/usage",
-		"header":    callHeader,
+		"method":    method,
+		"url":       url,
+		"header":    header,
+	}
+	if data != "" {
+		payload["data"] = data
 	}
+	return payload
 }
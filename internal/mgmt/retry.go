@@ -0,0 +1,60 @@
+package mgmt
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPError 管理接口返回的 4xx/5xx，RetryAfter 来自响应的 Retry-After 头
+type HTTPError struct {
+	Op         string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("management %s http %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// ParseRetryAfter 解析 Retry-After（秒数或 HTTP 日期），无法解析时返回 0
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// UpstreamRetryAfter 从 api-call 响应中的上游 header 提取 Retry-After；
+// header 可能是 {"Retry-After": "3"} 或 {"Retry-After": ["3"]}
+func UpstreamRetryAfter(data map[string]any) time.Duration {
+	header, _ := data["header"].(map[string]any)
+	for k, v := range header {
+		if !strings.EqualFold(k, "Retry-After") {
+			continue
+		}
+		switch val := v.(type) {
+		case string:
+			return ParseRetryAfter(val, time.Now())
+		case []any:
+			if len(val) > 0 {
+				s, _ := val[0].(string)
+				return ParseRetryAfter(s, time.Now())
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,23 @@
+package mgmt
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := ParseRetryAfter("7", now); d != 7*time.Second {
+		t.Fatalf("expected 7s, got %s", d)
+	}
+	if d := ParseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now); d != 90*time.Second {
+		t.Fatalf("expected 90s, got %s", d)
+	}
+	if d := ParseRetryAfter("soon", now); d != 0 {
+		t.Fatalf("expected 0, got %s", d)
+	}
+	if d := UpstreamRetryAfter(map[string]any{"header": map[string]any{"retry-after": []any{"2"}}}); d != 2*time.Second {
+		t.Fatalf("expected 2s, got %s", d)
+	}
+}
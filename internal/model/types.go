@@ -1,34 +1,82 @@
 package model
 
+import "time"
+
 const (
 	DefaultBaseURL    = "http://fnos.740110.xyz:8317/"
 	DefaultUA         = "codex_cli_rs/0.76.0 (Debian 13.0.0; x86_64) WindowsTerminal"
 	DefaultTimeout    = 12
 	DefaultConfigPath = "config.json"
 	DefaultOutput     = "invalid_codex_accounts.json"
+	DefaultHistory    = "probe_history.json"
+	DefaultPlanOutput = "dry_run_delete_plan.json"
+	DefaultBackupDir  = "auth_backups"
+)
+
+// Version 工具版本，写入 output 的生成信息；发布构建时通过
+// -ldflags "-X clean_codex_token/internal/model.Version=v1.2.3" 覆盖
+var Version = "dev"
+
+// 失效账号处置策略
+const (
+	StrategyDelete     = "delete"     // 直接删除
+	StrategyDisable    = "disable"    // 先通过管理接口禁用并本地隔离，确认后再删除
+	StrategyQuarantine = "quarantine" // 仅本地隔离观察，确认后再删除
+)
+
+// DeleteResult.Action 取值
+const (
+	ActionDelete     = "delete"
+	ActionDisable    = "disable"
+	ActionQuarantine = "quarantine"
+	ActionHold       = "hold" // 已在隔离中，尚未满足删除条件
+	ActionSkip       = "skip" // 删除前复查已恢复正常，跳过删除
 )
 
 type AuthFile map[string]any
 
 type ProbeResult struct {
-	Name           string `json:"name"`
-	Account        string `json:"account"`
-	AuthIndex      string `json:"auth_index"`
-	Type           string `json:"type"`
-	Provider       string `json:"provider"`
-	StatusCode     *int   `json:"status_code"`
-	Invalid401     bool   `json:"invalid_401"`
-	Error          string `json:"error"`
-	ErrorCount     int    `json:"error_count,omitempty"`
-	InvalidByError bool   `json:"invalid_by_error,omitempty"`
-	InvalidByLimit bool   `json:"invalid_by_limit,omitempty"`
+	Name       string  `json:"name"`
+	Account    string  `json:"account"`
+	AuthIndex  string  `json:"auth_index"`
+	Type       string  `json:"type"`
+	Provider   string  `json:"provider"`
+	Probe      string  `json:"probe,omitempty"`
+	StatusCode *int    `json:"status_code"`
+	Verdict    Verdict `json:"verdict"`
+	Reason     string  `json:"reason,omitempty"`
+	Action     string  `json:"action"`
+	Error      string  `json:"error"`
+	ErrorCount int     `json:"error_count,omitempty"`
+	LatencyMS  int64   `json:"latency_ms,omitempty"`
+	// Usage 探测响应中的额度信息，探测定义未配置 usage 或响应中没有时为 nil
+	Usage *Usage `json:"usage,omitempty"`
+
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	FirstFailingAt      *time.Time `json:"first_failing_at,omitempty"`
+	LastOKAt            *time.Time `json:"last_ok_at,omitempty"`
+	// CoolingUntil 额度耗尽、冷却等待重置的截止时间；冷却中的账号不处置
+	CoolingUntil *time.Time `json:"cooling_until,omitempty"`
+}
+
+type DeleteCandidate struct {
+	Name      string  `json:"name"`
+	Account   string  `json:"account,omitempty"`
+	AuthIndex string  `json:"auth_index,omitempty"`
+	Verdict   Verdict `json:"verdict,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Action    string  `json:"action,omitempty"`
 }
 
 type DeleteResult struct {
-	Name       string `json:"name"`
-	Deleted    bool   `json:"deleted"`
-	StatusCode int    `json:"status_code,omitempty"`
-	Error      string `json:"error"`
+	Name       string  `json:"name"`
+	Deleted    bool    `json:"deleted"`
+	DryRun     bool    `json:"dry_run,omitempty"`
+	Action     string  `json:"action,omitempty"`
+	Verdict    Verdict `json:"verdict,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	StatusCode int     `json:"status_code,omitempty"`
+	Error      string  `json:"error"`
 }
 
 type Options struct {
@@ -42,13 +90,52 @@ type Options struct {
 	DeleteWorkers    int
 	Timeout          int
 	Retries          int
+	ProbeRPS         float64
 	UserAgent        string
 	ChatgptAccountID string
 	Output           string
+	OutputFormat     string
+	ReportPath       string
+	TargetNames      string
+	HistoryPath      string
+	PlanOutput       string
+	BackupDir        string
+	NoBackup         bool
+	RestoreDir       string
+	RestoreNames     string
+	RestoreForce     bool
+	Strategy         string
+	QuarantineFails  int
+	QuarantineWindow time.Duration
+	PolicySpec       string
+	Policy           Policy
 	Cron             string
+	CronTZ           string
+	MetricsAddr      string
+	Listen           string
+	APIToken         string
+	LogFormat        string
+	LogLevel         string
 	Delete           bool
 	DeleteFromOutput bool
 	Yes              bool
+	DryRun           bool
+	DrainTimeout     time.Duration
+	MaxDeleteRatio   float64
+	MaxDelete        int
+	MinKeep          int
+	Canaries         string
+	CanaryMode       string
+	Reverify         bool
+	MaxOutputAge     time.Duration
+	Filter           string
+	Allow            string
+	Protect          string
+	InspectProbe     bool
+	CoolingCycles    int
+	ListFormat       string
+	ListColumns      string
+	ListGroupBy      string
 }
 
 type HarContext struct {
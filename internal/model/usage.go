@@ -0,0 +1,168 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// NearLimitPercent 用量达到该百分比视为接近上限
+const NearLimitPercent = 90
+
+// UsageWindow 一个限额窗口（如 5 小时 / 每周）
+type UsageWindow struct {
+	UsedPercent   *float64   `json:"used_percent,omitempty"`
+	WindowSeconds int64      `json:"window_seconds,omitempty"`
+	ResetAt       *time.Time `json:"reset_at,omitempty"`
+}
+
+// Usage 从探测响应中提取的额度信息；响应中没有的字段保持为空
+type Usage struct {
+	PlanType string   `json:"plan_type,omitempty"`
+	Used     *float64 `json:"used,omitempty"`
+	Limit    *float64 `json:"limit,omitempty"`
+	// UsedPercent 有 used/limit 时按其计算，否则取各窗口中最高的 used_percent
+	UsedPercent  *float64     `json:"used_percent,omitempty"`
+	LimitReached bool         `json:"limit_reached,omitempty"`
+	Primary      *UsageWindow `json:"primary_window,omitempty"`
+	Secondary    *UsageWindow `json:"secondary_window,omitempty"`
+}
+
+func (u *Usage) windows() []*UsageWindow {
+	var ws []*UsageWindow
+	for _, w := range []*UsageWindow{u.Primary, u.Secondary} {
+		if w != nil {
+			ws = append(ws, w)
+		}
+	}
+	return ws
+}
+
+// Normalize 在 used/limit 与窗口之间补全 UsedPercent 与 LimitReached
+func (u *Usage) Normalize() {
+	if u.UsedPercent == nil {
+		switch {
+		case u.Used != nil && u.Limit != nil && *u.Limit > 0:
+			p := math.Min(*u.Used / *u.Limit * 100, 100)
+			u.UsedPercent = &p
+		case u.Limit != nil && *u.Limit == 0:
+			p := 100.0
+			u.UsedPercent = &p
+		default:
+			for _, w := range u.windows() {
+				if w.UsedPercent != nil && (u.UsedPercent == nil || *w.UsedPercent > *u.UsedPercent) {
+					p := *w.UsedPercent
+					u.UsedPercent = &p
+				}
+			}
+		}
+	}
+	if u.UsedPercent != nil && *u.UsedPercent >= 100 {
+		u.LimitReached = true
+	}
+}
+
+// ResetAt 额度恢复时间：已用尽窗口中最晚的重置时间；没有用尽的窗口时取最早的重置时间
+func (u *Usage) ResetAt() *time.Time {
+	if u == nil {
+		return nil
+	}
+	var exhausted, earliest *time.Time
+	for _, w := range u.windows() {
+		if w.ResetAt == nil {
+			continue
+		}
+		if w.UsedPercent != nil && *w.UsedPercent >= 100 && (exhausted == nil || w.ResetAt.After(*exhausted)) {
+			exhausted = w.ResetAt
+		}
+		if earliest == nil || w.ResetAt.Before(*earliest) {
+			earliest = w.ResetAt
+		}
+	}
+	if exhausted != nil {
+		return exhausted
+	}
+	return earliest
+}
+
+// Capacity 账号池的额度概况，只统计探测结论为 healthy 的账号
+type Capacity struct {
+	Healthy int `json:"healthy"`
+	// WithUsage 有额度信息（used_percent）的健康账号数，平均值与余量只按这些账号计算
+	WithUsage      int            `json:"with_usage"`
+	Plans          map[string]int `json:"plans"`
+	AvgUsedPercent float64        `json:"avg_used_percent"`
+	// Headroom 剩余额度折合的账号数：Σ(100-used_percent)/100
+	Headroom  float64 `json:"headroom"`
+	NearLimit int     `json:"near_limit"`
+	// Used / Limit 有 used 与 limit 的健康账号合计
+	Used  float64 `json:"used,omitempty"`
+	Limit float64 `json:"limit,omitempty"`
+	// Exhausted 额度耗尽（quota-exhausted）的账号数，NextResetAt 为其中最早恢复的时间
+	Exhausted   int        `json:"exhausted"`
+	NextResetAt *time.Time `json:"next_reset_at,omitempty"`
+}
+
+// SummarizeCapacity 汇总一轮探测结果的额度概况
+func SummarizeCapacity(results []ProbeResult) Capacity {
+	c := Capacity{Plans: map[string]int{}}
+	var sum float64
+	for _, r := range results {
+		switch r.Verdict {
+		case VerdictQuotaExhausted:
+			c.Exhausted++
+			at := r.Usage.ResetAt()
+			if at == nil {
+				at = r.CoolingUntil
+			}
+			if at != nil && (c.NextResetAt == nil || at.Before(*c.NextResetAt)) {
+				c.NextResetAt = at
+			}
+			continue
+		case VerdictHealthy:
+		default:
+			continue
+		}
+		c.Healthy++
+		u := r.Usage
+		if u == nil {
+			continue
+		}
+		if u.PlanType != "" {
+			c.Plans[u.PlanType]++
+		}
+		if u.Used != nil && u.Limit != nil {
+			c.Used += *u.Used
+			c.Limit += *u.Limit
+		}
+		if u.UsedPercent == nil {
+			continue
+		}
+		p := math.Max(0, math.Min(*u.UsedPercent, 100))
+		c.WithUsage++
+		sum += p
+		c.Headroom += (100 - p) / 100
+		if p >= NearLimitPercent {
+			c.NearLimit++
+		}
+	}
+	if c.WithUsage > 0 {
+		c.AvgUsedPercent = sum / float64(c.WithUsage)
+	}
+	return c
+}
+
+// PlanNames 按账号数降序排列的套餐名
+func (c Capacity) PlanNames() []string {
+	names := make([]string, 0, len(c.Plans))
+	for p := range c.Plans {
+		names = append(names, p)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if c.Plans[names[i]] != c.Plans[names[j]] {
+			return c.Plans[names[i]] > c.Plans[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
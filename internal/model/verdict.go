@@ -0,0 +1,140 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Verdict 单个账号的探测结论
+type Verdict string
+
+const (
+	VerdictHealthy        Verdict = "healthy"
+	VerdictUnauthorized   Verdict = "unauthorized"
+	VerdictForbidden      Verdict = "forbidden"
+	VerdictRateLimited    Verdict = "rate-limited"
+	VerdictQuotaExhausted Verdict = "quota-exhausted"
+	VerdictUpstreamError  Verdict = "upstream-error"
+	VerdictTransportError Verdict = "transport-error"
+	VerdictUnknown        Verdict = "unknown"
+)
+
+// Verdicts 按展示顺序列出全部结论
+var Verdicts = []Verdict{
+	VerdictHealthy,
+	VerdictUnauthorized,
+	VerdictForbidden,
+	VerdictRateLimited,
+	VerdictQuotaExhausted,
+	VerdictUpstreamError,
+	VerdictTransportError,
+	VerdictUnknown,
+}
+
+func ParseVerdict(s string) (Verdict, bool) {
+	for _, v := range Verdicts {
+		if string(v) == strings.ToLower(strings.TrimSpace(s)) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// IsError 该结论是否属于探测异常（计入连续异常次数）。只有调用管理接口失败算异常；
+// unknown（未识别的状态码、缺少 status_code）多为上游整体故障，不计入，避免连续几轮后误删整个账号池
+func (v Verdict) IsError() bool {
+	return v == VerdictTransportError
+}
+
+// VerdictForStatus 无规则命中时按上游 HTTP 状态码给出结论
+func VerdictForStatus(code int) Verdict {
+	switch {
+	case code >= 200 && code < 300:
+		return VerdictHealthy
+	case code == 401:
+		return VerdictUnauthorized
+	case code == 403:
+		return VerdictForbidden
+	case code == 429:
+		return VerdictRateLimited
+	case code >= 500:
+		return VerdictUpstreamError
+	default:
+		return VerdictUnknown
+	}
+}
+
+// 每种结论的处置方式
+const (
+	PolicyKeep       = "keep"
+	PolicyDelete     = "delete"
+	PolicyQuarantine = "quarantine"
+)
+
+type Policy map[Verdict]string
+
+// DefaultPolicy 401 与限额耗尽删除，其余保留
+func DefaultPolicy() Policy {
+	return Policy{
+		VerdictUnauthorized:   PolicyDelete,
+		VerdictQuotaExhausted: PolicyDelete,
+	}
+}
+
+func (p Policy) ActionFor(v Verdict) string {
+	if a, ok := p[v]; ok {
+		return a
+	}
+	return PolicyKeep
+}
+
+// Set 设置单条策略，例如 Set("forbidden", "quarantine")
+func (p Policy) Set(verdict, action string) error {
+	v, ok := ParseVerdict(verdict)
+	if !ok {
+		return fmt.Errorf("未知结论: %q", verdict)
+	}
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case PolicyKeep, PolicyDelete, PolicyQuarantine:
+		p[v] = action
+		return nil
+	default:
+		return fmt.Errorf("未知处置方式: %q（可选 keep / delete / quarantine）", action)
+	}
+}
+
+// ParsePolicySpec 解析 "unauthorized=delete,forbidden=quarantine" 并合并到 p
+func (p Policy) ParsePolicySpec(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("非法策略: %q", part)
+		}
+		if err := p.Set(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Policy) Clone() Policy {
+	c := make(Policy, len(p))
+	for k, v := range p {
+		c[k] = v
+	}
+	return c
+}
+
+func (p Policy) String() string {
+	parts := make([]string, 0, len(p))
+	for v, a := range p {
+		parts = append(parts, string(v)+"="+a)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,283 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/report"
+)
+
+// 通知渠道
+const (
+	TypeWebhook  = "webhook"
+	TypeSlack    = "slack"
+	TypeTelegram = "telegram"
+	TypeDingTalk = "dingtalk"
+	TypeFeishu   = "feishu"
+	TypeWeCom    = "wecom"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// DefaultTemplate 默认消息模板，数据为 Message
+const DefaultTemplate = `[clean-codex-accounts]{{if .Report.Target}}[{{.Report.Target}}]{{end}} {{.Mode}} {{if .Report.Error}}失败{{else if .Report.Interrupted}}已中断{{else}}完成{{end}}
+服务: {{.Report.Options.BaseURL}}
+耗时: {{.Duration}}
+账号: 总数={{.Report.Counts.Total}} 匹配={{.Report.Counts.Matched}} 已探测={{.Report.Counts.Probed}} 失效={{.Report.Counts.Invalid}}
+处置: 删除成功={{.Report.Counts.Deleted}} 删除失败={{.Report.Counts.DeleteFailed}} 禁用={{.Report.Counts.Disabled}} 隔离={{.Report.Counts.Quarantined}} 观察中={{.Report.Counts.Held}}{{if .Report.Counts.Skipped}} 复查恢复={{.Report.Counts.Skipped}}{{end}}{{if .Report.Options.DryRun}} 计划删除={{.Report.Counts.Planned}}（dry-run）{{end}}
+{{- if .Report.Error}}
+错误: {{.Report.Error}}{{end}}
+{{- if .Deleted}}
+已删除: {{join .Deleted ", "}}{{end}}
+{{- if .Failed}}
+删除失败: {{join .Failed ", "}}{{end}}`
+
+// Config 对应 config.json 中的 notify
+type Config struct {
+	// MinInvalid / MinDeleted 均为 0 时每轮都通知；否则失效数 >= MinInvalid 或删除数 >= MinDeleted（取已设置的条件）才通知。
+	// 运行出错或被中断时总会通知。
+	MinInvalid int      `json:"min_invalid"`
+	MinDeleted int      `json:"min_deleted"`
+	Template   string   `json:"template"`
+	Targets    []Target `json:"targets"`
+}
+
+type Target struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Secret 钉钉/飞书机器人的加签密钥
+	Secret   string            `json:"secret"`
+	BotToken string            `json:"bot_token"`
+	ChatID   string            `json:"chat_id"`
+	Template string            `json:"template"`
+	Header   map[string]string `json:"header"`
+
+	tmpl *template.Template
+}
+
+// Message 模板数据
+type Message struct {
+	Report   *report.Report
+	Mode     string
+	Duration string
+	// Deleted / Failed / Quarantined 分别为删除成功、删除失败、禁用或隔离的账号名
+	Deleted     []string
+	Failed      []string
+	Quarantined []string
+}
+
+type Notifier struct {
+	Config
+	HTTPClient *http.Client
+	Now        func() time.Time
+}
+
+// Parse 解析 config.json 中的 notify；未配置或没有 targets 时返回 nil
+func Parse(raw any) (*Notifier, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("notify 配置错误: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("notify 配置错误: %w", err)
+	}
+	if len(c.Targets) == 0 {
+		return nil, nil
+	}
+	if c.Template == "" {
+		c.Template = DefaultTemplate
+	}
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		t.Type = strings.ToLower(strings.TrimSpace(t.Type))
+		switch t.Type {
+		case TypeWebhook, TypeSlack, TypeDingTalk, TypeFeishu, TypeWeCom:
+			if t.URL == "" {
+				return nil, fmt.Errorf("notify.targets[%d] 缺少 url", i)
+			}
+		case TypeTelegram:
+			if t.BotToken == "" || t.ChatID == "" {
+				return nil, fmt.Errorf("notify.targets[%d] 缺少 bot_token 或 chat_id", i)
+			}
+		default:
+			return nil, fmt.Errorf("notify.targets[%d] 未知 type: %q（可选 webhook / slack / telegram / dingtalk / feishu / wecom）", i, t.Type)
+		}
+		text := t.Template
+		if text == "" {
+			text = c.Template
+		}
+		tmpl, err := template.New(t.Type).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("notify.targets[%d] 模板错误: %w", i, err)
+		}
+		t.tmpl = tmpl
+	}
+	return &Notifier{Config: c, HTTPClient: &http.Client{Timeout: 10 * time.Second}, Now: time.Now}, nil
+}
+
+// ShouldNotify 按阈值判断本轮是否需要通知
+func (n *Notifier) ShouldNotify(rep *report.Report) bool {
+	if rep.Error != "" || rep.Interrupted {
+		return true
+	}
+	if n.MinInvalid <= 0 && n.MinDeleted <= 0 {
+		return true
+	}
+	return (n.MinInvalid > 0 && rep.Counts.Invalid >= n.MinInvalid) ||
+		(n.MinDeleted > 0 && rep.Counts.Deleted >= n.MinDeleted)
+}
+
+// Notify 向全部渠道发送本轮摘要；单个渠道失败不影响其他渠道，错误合并返回。n 为 nil 时不做任何事。
+func (n *Notifier) Notify(ctx context.Context, rep *report.Report) error {
+	if n == nil || rep == nil || !n.ShouldNotify(rep) {
+		return nil
+	}
+	msg := newMessage(rep)
+	var errs []error
+	for i, t := range n.Targets {
+		var text bytes.Buffer
+		if err := t.tmpl.Execute(&text, msg); err != nil {
+			errs = append(errs, fmt.Errorf("notify.targets[%d](%s): %w", i, t.Type, err))
+			continue
+		}
+		if err := n.send(ctx, t, text.String(), msg); err != nil {
+			errs = append(errs, fmt.Errorf("notify.targets[%d](%s): %w", i, t.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newMessage(rep *report.Report) Message {
+	m := Message{Report: rep, Mode: rep.Mode, Duration: (time.Duration(rep.DurationMS) * time.Millisecond).Round(time.Second).String()}
+	for _, d := range rep.Deletes {
+		switch {
+		case d.DryRun || d.Action == model.ActionHold || d.Action == model.ActionSkip:
+		case d.Action == model.ActionDisable || d.Action == model.ActionQuarantine:
+			if d.Error == "" {
+				m.Quarantined = append(m.Quarantined, d.Name)
+			}
+		case d.Deleted:
+			m.Deleted = append(m.Deleted, d.Name)
+		default:
+			m.Failed = append(m.Failed, d.Name)
+		}
+	}
+	return m
+}
+
+func (n *Notifier) send(ctx context.Context, t Target, text string, msg Message) error {
+	target := t.URL
+	var body any
+	switch t.Type {
+	case TypeWebhook:
+		body = map[string]any{
+			"text":        text,
+			"mode":        msg.Mode,
+			"base_url":    msg.Report.Options.BaseURL,
+			"started_at":  msg.Report.StartedAt,
+			"finished_at": msg.Report.FinishedAt,
+			"interrupted": msg.Report.Interrupted,
+			"error":       msg.Report.Error,
+			"refused":     msg.Report.Refused,
+			"counts":      msg.Report.Counts,
+			"deleted":     msg.Deleted,
+			"failed":      msg.Failed,
+			"quarantined": msg.Quarantined,
+		}
+	case TypeSlack:
+		body = map[string]any{"text": text}
+	case TypeTelegram:
+		if target == "" {
+			target = telegramAPI
+		}
+		target = strings.TrimRight(target, "/") + "/bot" + t.BotToken + "/sendMessage"
+		body = map[string]any{"chat_id": t.ChatID, "text": text, "disable_web_page_preview": true}
+	case TypeDingTalk:
+		if t.Secret != "" {
+			ts := strconv.FormatInt(n.Now().UnixMilli(), 10)
+			sign := url.QueryEscape(hmacBase64(t.Secret, ts+"\n"+t.Secret))
+			target += sep(target) + "timestamp=" + ts + "&sign=" + sign
+		}
+		body = map[string]any{"msgtype": "text", "text": map[string]any{"content": text}}
+	case TypeFeishu:
+		m := map[string]any{"msg_type": "text", "content": map[string]any{"text": text}}
+		if t.Secret != "" {
+			ts := strconv.FormatInt(n.Now().Unix(), 10)
+			// 飞书以 timestamp + "\n" + secret 作为 HMAC 密钥、空串为消息
+			m["timestamp"] = ts
+			m["sign"] = hmacBase64(ts+"\n"+t.Secret, "")
+		}
+		body = m
+	case TypeWeCom:
+		body = map[string]any{"msgtype": "text", "text": map[string]any{"content": text}}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("无效的通知地址: %s", redactURL(target))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Header {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		// *url.Error 带完整地址，去掉后只保留底层原因
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST %s: %w", redactURL(target), err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("http %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// redactURL 只保留 scheme 与 host：通知地址的路径与参数中常含密钥（Telegram bot token、Slack/钉钉 webhook token），
+// 不能出现在错误与日志中
+func redactURL(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func hmacBase64(key, msg string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sep(u string) string {
+	if strings.Contains(u, "?") {
+		return "&"
+	}
+	return "?"
+}
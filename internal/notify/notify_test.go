@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/report"
+)
+
+type capture struct {
+	mu   sync.Mutex
+	reqs map[string]map[string]any
+}
+
+func newCapture(t *testing.T) (*capture, *httptest.Server) {
+	c := &capture{reqs: map[string]map[string]any{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var m map[string]any
+		_ = json.Unmarshal(b, &m)
+		m["_query"] = r.URL.RawQuery
+		c.mu.Lock()
+		c.reqs[r.URL.Path] = m
+		c.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func sampleReport() *report.Report {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := report.New(&model.Options{BaseURL: "http://cpa", Policy: model.DefaultPolicy()}, "cron", t0)
+	r.SetProbe(3, 3, []model.ProbeResult{
+		{Name: "a", Verdict: model.VerdictUnauthorized, Action: model.PolicyDelete},
+		{Name: "b", Verdict: model.VerdictHealthy, Action: model.PolicyKeep},
+		{Name: "c", Verdict: model.VerdictUnauthorized, Action: model.PolicyDelete},
+	})
+	r.AddDeletes([]model.DeleteResult{
+		{Name: "a", Deleted: true, Action: model.ActionDelete},
+		{Name: "c", Action: model.ActionDelete, Error: "boom"},
+	})
+	r.Finish(t0.Add(time.Minute), false, nil)
+	return r
+}
+
+func TestNotifyAllChannels(t *testing.T) {
+	c, srv := newCapture(t)
+	n, err := Parse(map[string]any{
+		"targets": []any{
+			map[string]any{"type": "webhook", "url": srv.URL + "/hook"},
+			map[string]any{"type": "slack", "url": srv.URL + "/slack"},
+			map[string]any{"type": "telegram", "url": srv.URL, "bot_token": "T", "chat_id": "42"},
+			map[string]any{"type": "dingtalk", "url": srv.URL + "/ding?access_token=x", "secret": "s"},
+			map[string]any{"type": "feishu", "url": srv.URL + "/feishu", "secret": "s"},
+			map[string]any{"type": "wecom", "url": srv.URL + "/wecom", "template": "deleted={{join .Deleted \",\"}}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), sampleReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	if text, _ := c.reqs["/slack"]["text"].(string); !strings.Contains(text, "已删除: a") || !strings.Contains(text, "删除失败: c") {
+		t.Fatalf("unexpected slack text: %q", text)
+	}
+	if hook := c.reqs["/hook"]; hook["mode"] != "cron" || len(hook["deleted"].([]any)) != 1 {
+		t.Fatalf("unexpected webhook body: %v", hook)
+	}
+	if tg := c.reqs["/botT/sendMessage"]; tg["chat_id"] != "42" || tg["text"] == "" {
+		t.Fatalf("unexpected telegram body: %v", tg)
+	}
+	ding := c.reqs["/ding"]
+	if q := ding["_query"].(string); !strings.Contains(q, "access_token=x&timestamp=") || !strings.Contains(q, "&sign=") || ding["msgtype"] != "text" {
+		t.Fatalf("unexpected dingtalk request: %v", ding)
+	}
+	if fs := c.reqs["/feishu"]; fs["msg_type"] != "text" || fs["sign"] == nil || fs["timestamp"] == nil {
+		t.Fatalf("unexpected feishu body: %v", fs)
+	}
+	if wc := c.reqs["/wecom"]["text"].(map[string]any); wc["content"] != "deleted=a" {
+		t.Fatalf("unexpected wecom content: %v", wc)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	n := &Notifier{Config: Config{MinInvalid: 5}}
+	rep := sampleReport()
+	if n.ShouldNotify(rep) {
+		t.Fatal("2 invalid should be below min_invalid=5")
+	}
+	n.MinDeleted = 1
+	if !n.ShouldNotify(rep) {
+		t.Fatal("1 deleted should reach min_deleted=1")
+	}
+	n.MinDeleted = 0
+	rep.Error = "fetch failed"
+	if !n.ShouldNotify(rep) {
+		t.Fatal("errors should always notify")
+	}
+}
+
+func TestParse(t *testing.T) {
+	if n, err := Parse(nil); n != nil || err != nil {
+		t.Fatalf("nil config should disable notify: %v %v", n, err)
+	}
+	if _, err := Parse(map[string]any{"targets": []any{map[string]any{"type": "pager"}}}); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+	if _, err := Parse(map[string]any{"targets": []any{map[string]any{"type": "telegram", "bot_token": "x"}}}); err == nil {
+		t.Fatal("expected error for missing chat_id")
+	}
+	if _, err := Parse(map[string]any{"template": "{{.Nope", "targets": []any{map[string]any{"type": "slack", "url": "http://x"}}}); err == nil {
+		t.Fatal("expected template error")
+	}
+}
+
+func TestSendErrorRedactsSecrets(t *testing.T) {
+	// 连接被拒绝时 http.Client 的错误带完整地址，其中包含 bot token
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+	n, err := Parse(map[string]any{
+		"targets": []any{
+			map[string]any{"type": "telegram", "url": addr, "bot_token": "123456:SECRET-TOKEN", "chat_id": "42"},
+			map[string]any{"type": "dingtalk", "url": addr + "/robot/send?access_token=SECRET-TOKEN", "secret": "s"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), sampleReport())
+	if err == nil {
+		t.Fatal("expected send to fail")
+	}
+	if msg := err.Error(); strings.Contains(msg, "SECRET-TOKEN") || !strings.Contains(msg, addr) {
+		t.Fatalf("error must name the host but not the token: %s", msg)
+	}
+}
@@ -0,0 +1,317 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+// 导出格式
+const (
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatMarkdown, "md":
+		return FormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("未知导出格式: %q（可选 json / ndjson / csv / markdown）", s)
+	}
+}
+
+// tableColumns csv / markdown 导出的列，LoadCandidatesFromOutput 按列名读取
+var tableColumns = []string{"name", "account", "auth_index", "type", "provider", "verdict", "status_code", "reason", "action", "error", "latency_ms", "plan_type", "used_percent", "reset_at"}
+
+// ResultWriter 导出探测结果；ndjson 每条结果到达即写入文件（进程被杀也能保留已写入部分），
+// 其他格式在 Close 时按名称排序后一次性写入
+type ResultWriter struct {
+	path   string
+	format string
+	meta   *Meta
+	rows   []model.ProbeResult
+	file   *os.File
+	buf    *bufio.Writer
+}
+
+// NewResultWriter meta 为 nil 时不写入生成信息（json 为纯数组）
+func NewResultWriter(path, format string, meta *Meta) (*ResultWriter, error) {
+	f, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	w := &ResultWriter{path: path, format: f, meta: meta, rows: make([]model.ProbeResult, 0)}
+	if f == FormatNDJSON {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w.file = file
+		w.buf = bufio.NewWriter(file)
+		if meta != nil {
+			if _, err := w.buf.Write(metaLine(`{"meta":`, meta, "}")); err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+		}
+	}
+	return w, nil
+}
+
+func (w *ResultWriter) Add(r model.ProbeResult) error {
+	if w.file == nil {
+		w.rows = append(w.rows, r)
+		return nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+func (w *ResultWriter) Close() error {
+	if w.file != nil {
+		if err := w.buf.Flush(); err != nil {
+			_ = w.file.Close()
+			return err
+		}
+		return w.file.Close()
+	}
+	sort.Slice(w.rows, func(i, j int) bool { return w.rows[i].Name < w.rows[j].Name })
+	switch w.format {
+	case FormatCSV:
+		return os.WriteFile(w.path, w.withMeta(csvMetaPrefix, encodeCSV(w.rows), ""), 0o644)
+	case FormatMarkdown:
+		return os.WriteFile(w.path, w.withMeta(markdownMetaPrefix, encodeMarkdown(w.rows), markdownMetaSuffix), 0o644)
+	default:
+		if w.meta == nil {
+			return WriteJSON(w.path, w.rows)
+		}
+		return WriteJSON(w.path, jsonDocument{Meta: w.meta, Accounts: w.rows})
+	}
+}
+
+func (w *ResultWriter) withMeta(prefix string, body []byte, suffix string) []byte {
+	if w.meta == nil {
+		return body
+	}
+	return append(metaLine(prefix, w.meta, suffix), body...)
+}
+
+func tableRow(r model.ProbeResult) []string {
+	sc := ""
+	if r.StatusCode != nil {
+		sc = strconv.Itoa(*r.StatusCode)
+	}
+	plan, used, reset := "", "", ""
+	if u := r.Usage; u != nil {
+		plan = u.PlanType
+		if u.UsedPercent != nil {
+			used = strconv.FormatFloat(*u.UsedPercent, 'f', -1, 64)
+		}
+		if at := u.ResetAt(); at != nil {
+			reset = at.Format(time.RFC3339)
+		}
+	}
+	return []string{r.Name, r.Account, r.AuthIndex, r.Type, r.Provider, string(r.Verdict), sc, r.Reason, r.Action, r.Error, strconv.FormatInt(r.LatencyMS, 10), plan, used, reset}
+}
+
+func encodeCSV(rows []model.ProbeResult) []byte {
+	var b bytes.Buffer
+	cw := csv.NewWriter(&b)
+	_ = cw.Write(tableColumns)
+	for _, r := range rows {
+		_ = cw.Write(tableRow(r))
+	}
+	cw.Flush()
+	return b.Bytes()
+}
+
+func encodeMarkdown(rows []model.ProbeResult) []byte {
+	var b bytes.Buffer
+	b.WriteString("| " + strings.Join(tableColumns, " | ") + " |\n")
+	b.WriteString(strings.Repeat("| --- ", len(tableColumns)) + "|\n")
+	for _, r := range rows {
+		cells := tableRow(r)
+		for i, c := range cells {
+			cells[i] = escapeMarkdownCell(c)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.Bytes()
+}
+
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r", " "), "\n", " ")
+}
+
+// decodeRows 按内容自动识别 json / ndjson / csv / markdown，返回生成信息（没有时为 nil）与每行的字段
+func decodeRows(b []byte) (*Meta, []map[string]any, error) {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	meta, trimmed, err := splitMeta(bytes.TrimSpace(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []map[string]any
+	switch {
+	case len(trimmed) == 0:
+	case trimmed[0] == '[':
+		err = json.Unmarshal(trimmed, &rows)
+	case trimmed[0] == '{':
+		var doc struct {
+			Meta     *Meta            `json:"meta"`
+			Accounts []map[string]any `json:"accounts"`
+		}
+		if json.Unmarshal(trimmed, &doc) == nil && doc.Accounts != nil {
+			return doc.Meta, doc.Accounts, nil
+		}
+		meta, rows, err = decodeNDJSON(trimmed)
+	case trimmed[0] == '|':
+		rows, err = decodeMarkdown(trimmed)
+	default:
+		rows, err = decodeCSV(trimmed)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, rows, nil
+}
+
+// decodeNDJSON 首行可以是 {"meta": ...}
+func decodeNDJSON(b []byte) (*Meta, []map[string]any, error) {
+	var meta *Meta
+	if line, rest, _ := bytes.Cut(b, []byte("\n")); bytes.HasPrefix(bytes.TrimSpace(line), []byte(`{"meta":`)) {
+		var head struct {
+			Meta *Meta `json:"meta"`
+		}
+		if err := json.Unmarshal(line, &head); err != nil {
+			return nil, nil, fmt.Errorf("解析 meta 失败: %w", err)
+		}
+		meta, b = head.Meta, rest
+	}
+	rows, err := decodeNDJSONRows(b)
+	return meta, rows, err
+}
+
+func decodeNDJSONRows(b []byte) ([]map[string]any, error) {
+	lines := strings.Split(string(b), "\n")
+	rows := make([]map[string]any, 0, len(lines))
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			if i == len(lines)-1 {
+				// 进程被中断时最后一行可能只写了一半
+				break
+			}
+			return nil, fmt.Errorf("第 %d 行: %w", i+1, err)
+		}
+		rows = append(rows, m)
+	}
+	return rows, nil
+}
+
+func decodeCSV(b []byte) ([]map[string]any, error) {
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return tableToRows(records)
+}
+
+func decodeMarkdown(b []byte) ([]map[string]any, error) {
+	records := make([][]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cells := splitMarkdownRow(line)
+		if isSeparatorRow(cells) {
+			continue
+		}
+		records = append(records, cells)
+	}
+	return tableToRows(records)
+}
+
+func splitMarkdownRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := make([]string, 0)
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+func isSeparatorRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.Trim(c, ":- ") != "" || c == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func tableToRows(records [][]string) ([]map[string]any, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	hasName := false
+	for i, h := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(h))
+		hasName = hasName || header[i] == "name"
+	}
+	if !hasName {
+		return nil, fmt.Errorf("表头缺少 name 列")
+	}
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		m := make(map[string]any, len(header))
+		for i, h := range header {
+			if i < len(rec) {
+				m[h] = rec[i]
+			}
+		}
+		rows = append(rows, m)
+	}
+	return rows, nil
+}
@@ -0,0 +1,126 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestFormatsRoundTrip(t *testing.T) {
+	sc := 401
+	rows := []model.ProbeResult{
+		{Name: "b", Account: "b@test", AuthIndex: "idx-b", Verdict: model.VerdictQuotaExhausted, Reason: "usage.limit=0 | plan", Action: model.PolicyQuarantine},
+		{Name: "a", Account: "a@test", AuthIndex: "idx-a", StatusCode: &sc, Verdict: model.VerdictUnauthorized, Reason: "HTTP 401", Action: model.PolicyDelete},
+	}
+	meta := &Meta{Tool: Tool, Version: "v1", GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), BaseURL: "http://mgmt", TargetType: "codex"}
+	for _, format := range []string{FormatJSON, FormatNDJSON, FormatCSV, FormatMarkdown} {
+		t.Run(format, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "out")
+			w, err := NewResultWriter(p, format, meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				if err := w.Add(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got, gotMeta, err := LoadCandidatesFromOutput(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotMeta == nil || *gotMeta != *meta {
+				t.Fatalf("meta not preserved: %+v", gotMeta)
+			}
+			byName := map[string]model.DeleteCandidate{}
+			for _, c := range got {
+				byName[c.Name] = c
+			}
+			if len(got) != 2 || byName["a"].Verdict != model.VerdictUnauthorized || byName["a"].AuthIndex != "idx-a" {
+				t.Fatalf("unexpected candidates: %+v", got)
+			}
+			if b := byName["b"]; b.Action != model.PolicyQuarantine || b.Reason != "usage.limit=0 | plan" || b.Account != "b@test" {
+				t.Fatalf("unexpected candidate b: %+v", b)
+			}
+		})
+	}
+}
+
+func TestNDJSONToleratesTruncatedTail(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "out.ndjson")
+	content := `{"name":"a","verdict":"unauthorized","action":"delete"}` + "\n" + `{"name":"b","verd`
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, meta, err := LoadCandidatesFromOutput(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta != nil || len(got) != 1 || got[0].Name != "a" {
+		t.Fatalf("unexpected candidates: %+v", got)
+	}
+}
+
+func TestNDJSONWritesIncrementally(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "out.ndjson")
+	w, err := NewResultWriter(p, FormatNDJSON, &Meta{Tool: Tool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Add(model.ProbeResult{Name: "a", Verdict: model.VerdictUnauthorized, Action: model.PolicyDelete}); err != nil {
+		t.Fatal(err)
+	}
+	// 未 Close 时已写入的行即可读取
+	got, _, err := LoadCandidatesFromOutput(p)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("expected one candidate before close, got %+v err=%v", got, err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("MD"); err != nil || f != FormatMarkdown {
+		t.Fatalf("unexpected: %q %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLegacyJSONArrayHasNoMeta(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "out.json")
+	if err := os.WriteFile(p, []byte(`[{"name":"a","invalid_401":true}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, meta, err := LoadCandidatesFromOutput(p)
+	if err != nil || meta != nil || len(got) != 1 || got[0].Verdict != model.VerdictUnauthorized {
+		t.Fatalf("unexpected: %+v %+v %v", got, meta, err)
+	}
+}
+
+func TestMetaCheck(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	m := &Meta{BaseURL: "http://mgmt:8317/", GeneratedAt: now.Add(-2 * time.Hour)}
+	if err := m.Check("http://MGMT:8317", time.Hour*3, now); err != nil {
+		t.Fatalf("expected fresh output from same server: %v", err)
+	}
+	if err := m.Check("http://other:8317", 0, now); err == nil {
+		t.Fatal("expected server mismatch")
+	}
+	if err := m.Check("http://mgmt:8317", time.Hour, now); err == nil {
+		t.Fatal("expected stale output")
+	}
+	if err := m.Check("http://mgmt:8317", 0, now); err != nil {
+		t.Fatalf("max age 0 should disable age check: %v", err)
+	}
+	var none *Meta
+	if err := none.Check("http://other", time.Second, now); err != nil {
+		t.Fatalf("nil meta cannot be checked: %v", err)
+	}
+}
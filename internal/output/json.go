@@ -4,26 +4,70 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"clean_codex_token/internal/model"
 )
 
-func LoadNamesFromOutput(path string) ([]string, error) {
+func WriteJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// LoadCandidatesFromOutput 读取 output 文件中的账号及失效原因，自动识别 json / ndjson / csv / markdown；
+// 旧版或手写的文件没有生成信息，此时 meta 为 nil
+func LoadCandidatesFromOutput(path string) ([]model.DeleteCandidate, *Meta, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("读取 output 文件失败: %w", err)
+		return nil, nil, fmt.Errorf("读取 output 文件失败: %w", err)
 	}
-	var rows any
-	if err := json.Unmarshal(b, &rows); err != nil {
-		return nil, fmt.Errorf("读取 output 文件失败: %w", err)
+	meta, rows, err := decodeRows(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 output 文件失败: %w", err)
 	}
 
-	arr, _ := rows.([]any)
-	names := make([]string, 0, len(arr))
-	for _, row := range arr {
-		m, _ := row.(map[string]any)
+	candidates := make([]model.DeleteCandidate, 0, len(rows))
+	for _, m := range rows {
 		name, _ := m["name"].(string)
-		if name != "" {
-			names = append(names, name)
+		if name == "" {
+			continue
+		}
+		c := model.DeleteCandidate{Name: name}
+		c.Account, _ = m["account"].(string)
+		c.AuthIndex, _ = m["auth_index"].(string)
+		c.Reason, _ = m["reason"].(string)
+		c.Action, _ = m["action"].(string)
+		if v, _ := m["verdict"].(string); v != "" {
+			c.Verdict, _ = model.ParseVerdict(v)
+		} else {
+			c.Verdict = legacyVerdict(m)
 		}
+		if c.Action == "" || c.Action == model.PolicyKeep {
+			// output 中的账号即为用户确认要处置的账号
+			c.Action = model.PolicyDelete
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, meta, nil
+}
+
+// legacyVerdict 兼容旧版 output 中的 invalid_401 / invalid_by_limit / invalid_by_error
+func legacyVerdict(m map[string]any) model.Verdict {
+	switch {
+	case isTrue(m["invalid_401"]):
+		return model.VerdictUnauthorized
+	case isTrue(m["invalid_by_limit"]):
+		return model.VerdictQuotaExhausted
+	case isTrue(m["invalid_by_error"]):
+		return model.VerdictTransportError
+	default:
+		return ""
 	}
-	return names, nil
+}
+
+func isTrue(v any) bool {
+	b, _ := v.(bool)
+	return b
 }
@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tool 写入 Meta.Tool 的工具名称
+const Tool = "clean-codex-accounts"
+
+// 各格式中承载 Meta 的首行前缀；json 为 {"meta": ..., "accounts": [...]}，ndjson 首行为 {"meta": ...}
+const (
+	csvMetaPrefix      = "# meta: "
+	markdownMetaPrefix = "<!-- meta: "
+	markdownMetaSuffix = " -->"
+)
+
+// Meta output 文件的生成信息，--delete-from-output 据此判断文件是否过期、是否属于当前管理服务
+type Meta struct {
+	Tool        string    `json:"tool"`
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	BaseURL     string    `json:"base_url"`
+	TargetType  string    `json:"target_type"`
+	Provider    string    `json:"provider,omitempty"`
+	Filter      string    `json:"filter,omitempty"`
+}
+
+// jsonDocument json 格式的完整结构
+type jsonDocument struct {
+	Meta     *Meta `json:"meta"`
+	Accounts any   `json:"accounts"`
+}
+
+func metaLine(prefix string, m *Meta, suffix string) []byte {
+	b, _ := json.Marshal(m)
+	return []byte(prefix + string(b) + suffix + "\n")
+}
+
+// splitMeta 取出 csv / markdown 首行的 Meta，返回其余内容；没有 Meta 时原样返回
+func splitMeta(b []byte) (*Meta, []byte, error) {
+	line, rest, _ := bytes.Cut(b, []byte("\n"))
+	s := strings.TrimSpace(string(line))
+	var raw string
+	switch {
+	case strings.HasPrefix(s, csvMetaPrefix):
+		raw = strings.TrimPrefix(s, csvMetaPrefix)
+	case strings.HasPrefix(s, markdownMetaPrefix) && strings.HasSuffix(s, markdownMetaSuffix):
+		raw = strings.TrimSuffix(strings.TrimPrefix(s, markdownMetaPrefix), markdownMetaSuffix)
+	default:
+		return nil, b, nil
+	}
+	var m Meta
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, nil, fmt.Errorf("解析 meta 失败: %w", err)
+	}
+	return &m, bytes.TrimSpace(rest), nil
+}
+
+// Check 校验 output 是否由 baseURL 对应的管理服务在 maxAge 内生成；maxAge 为 0 时不检查时效。
+// m 为 nil（旧版或手写文件）时无法校验，直接返回 nil
+func (m *Meta) Check(baseURL string, maxAge time.Duration, now time.Time) error {
+	if m == nil {
+		return nil
+	}
+	if !strings.EqualFold(strings.TrimRight(m.BaseURL, "/"), strings.TrimRight(baseURL, "/")) {
+		return fmt.Errorf("output 由其他管理服务生成（%s），当前为 %s", m.BaseURL, baseURL)
+	}
+	if age := now.Sub(m.GeneratedAt); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("output 生成于 %s（%s 前），超过 --max-output-age %s，请重新检测", m.GeneratedAt.Local().Format("2006-01-02 15:04:05"), age.Round(time.Second), maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package probe
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"clean_codex_token/internal/shutdown"
+)
+
+const (
+	backoffBase = 500 * time.Millisecond
+	backoffMax  = 30 * time.Second
+	// maxRetryAfter Retry-After 上限，避免单个账号长时间占住 worker
+	maxRetryAfter = 2 * time.Minute
+)
+
+// backoffDelay 第 attempt 次重试前的等待：指数退避 + 抖动，Retry-After 更长时以其为准
+func backoffDelay(attempt int, retryAfter time.Duration) time.Duration {
+	d := backoffBase << (attempt - 1)
+	if d > backoffMax || d <= 0 {
+		d = backoffMax
+	}
+	d = d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+	if retryAfter > maxRetryAfter {
+		retryAfter = maxRetryAfter
+	}
+	if retryAfter > d {
+		return retryAfter
+	}
+	return d
+}
+
+// sleepCtx 等待 d；收到退出信号时不再发起新的重试
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-shutdown.Stopping(ctx):
+		return shutdown.ErrInterrupted
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
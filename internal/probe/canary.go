@@ -0,0 +1,82 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/model"
+)
+
+// 金丝雀账号未通过探测时的处理方式
+const (
+	CanaryReport = "report" // 继续探测并导出结果，但本轮不处置任何账号
+	CanaryAbort  = "abort"  // 立即结束本轮
+)
+
+// CanaryError 金丝雀账号未探测为 healthy，本轮结论不可信
+type CanaryError struct {
+	// Phase 探测前（pre）或探测结束后复查（post）
+	Phase  string
+	Name   string
+	Reason string
+}
+
+func (e *CanaryError) Error() string {
+	phase := "探测前"
+	if e.Phase == "post" {
+		phase = "探测结束后复查"
+	}
+	return fmt.Sprintf("%s金丝雀账号 %s 未通过: %s，本轮结论不可信", phase, e.Name, e.Reason)
+}
+
+// canaryKeys findCanaries 选中的金丝雀文件的标识（auth_index，缺少时为 name）；
+// 与金丝雀共享 account 的其他文件不在其中，照常探测、处置
+func canaryKeys(canaries []model.AuthFile) []string {
+	keys := make([]string, 0, len(canaries))
+	for _, f := range canaries {
+		r := Identity(f)
+		keys = append(keys, history.Key(r.Name, r.AuthIndex))
+	}
+	return keys
+}
+
+// findCanaries 返回每个金丝雀对应的 auth 文件；找不到的金丝雀视为未通过
+func (s *Service) findCanaries(files []model.AuthFile) ([]model.AuthFile, *CanaryError) {
+	found := make([]model.AuthFile, 0, len(s.Canaries))
+	for _, c := range s.Canaries {
+		var hit model.AuthFile
+		for _, f := range files {
+			if r := Identity(f); c == r.Name || c == r.Account || c == r.AuthIndex {
+				hit = f
+				break
+			}
+		}
+		if hit == nil {
+			return nil, &CanaryError{Phase: "pre", Name: c, Reason: "管理服务中不存在该账号"}
+		}
+		found = append(found, hit)
+	}
+	return found, nil
+}
+
+// probeCanaries 依次探测金丝雀账号，返回结果与第一个未通过的账号
+func (s *Service) probeCanaries(ctx context.Context, canaries []model.AuthFile, opts *model.Options, phase string) ([]model.ProbeResult, *CanaryError) {
+	results := make([]model.ProbeResult, 0, len(canaries))
+	var failed *CanaryError
+	for _, f := range canaries {
+		started := time.Now()
+		r := s.probeOneWithRetry(ctx, f, opts)
+		r.LatencyMS = time.Since(started).Milliseconds()
+		results = append(results, r)
+		if r.Verdict != model.VerdictHealthy && failed == nil {
+			reason := r.Reason
+			if reason == "" {
+				reason = string(r.Verdict)
+			}
+			failed = &CanaryError{Phase: phase, Name: r.Name, Reason: reason}
+		}
+	}
+	return results, failed
+}
@@ -0,0 +1,300 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"clean_codex_token/internal/mgmt"
+	"clean_codex_token/internal/model"
+)
+
+// Definition 描述一种 auth 文件的探测方式：通过 api-call 让管理服务带上账号凭据请求 URL，
+// 再按 Rules 依次匹配响应，首条命中的规则决定结论，均未命中时按上游状态码判定（model.VerdictForStatus）。
+// URL/Header/Body 支持占位符 {{name}} {{account}} {{auth_index}} {{user_agent}} {{chatgpt_account_id}}，
+// Header 值替换后为空则不发送。
+type Definition struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Provider string            `json:"provider"`
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Header   map[string]string `json:"header"`
+	Body     string            `json:"body"`
+	Rules    []Rule            `json:"rules"`
+	// Usage 从响应 body 提取额度信息，nil 时不提取
+	Usage *UsageFields `json:"usage"`
+}
+
+// Rule 状态码与 body JSON 路径条件同时满足时命中；未配置的条件视为满足
+type Rule struct {
+	StatusCodes []int         `json:"status_codes"`
+	Path        string        `json:"path"`
+	Op          string        `json:"op"`
+	Value       any           `json:"value"`
+	Result      model.Verdict `json:"result"`
+	Reason      string        `json:"reason"`
+}
+
+var builtinDefinitions = []Definition{
+	{
+		Name:   "codex-wham-usage",
+		Type:   "codex",
+		Method: "GET",
+		URL:    "https://chatgpt.com/backend-api/wham/usage",
+		Header: map[string]string{
+			"Authorization":      "Bearer $TOKEN$",
+			"Content-Type":       "application/json",
+			"User-Agent":         "{{user_agent}}",
+			"Chatgpt-Account-Id": "{{chatgpt_account_id}}",
+		},
+		Rules: []Rule{
+			{StatusCodes: []int{401}, Result: model.VerdictUnauthorized},
+			{Path: "usage.limit", Op: "eq", Value: float64(0), Result: model.VerdictQuotaExhausted, Reason: "usage.limit=0"},
+		},
+		Usage: &UsageFields{
+			Plan:         "plan_type",
+			Used:         "usage.used",
+			Limit:        "usage.limit",
+			LimitReached: "rate_limit.limit_reached",
+			Primary:      "rate_limit.primary_window",
+			Secondary:    "rate_limit.secondary_window",
+		},
+	},
+}
+
+type Registry struct {
+	defs []Definition
+}
+
+// NewRegistry 按顺序匹配 defs，之后回落到内置定义
+func NewRegistry(defs ...Definition) *Registry {
+	all := make([]Definition, 0, len(defs)+len(builtinDefinitions))
+	all = append(all, defs...)
+	all = append(all, builtinDefinitions...)
+	return &Registry{defs: all}
+}
+
+func (r *Registry) Lookup(item model.AuthFile) (Definition, bool) {
+	typ := mgmt.GetItemType(item)
+	provider := str(item["provider"])
+	for _, d := range r.defs {
+		if d.Type != "" && !strings.EqualFold(d.Type, typ) {
+			continue
+		}
+		if d.Provider != "" && !strings.EqualFold(d.Provider, provider) {
+			continue
+		}
+		return d, true
+	}
+	return Definition{}, false
+}
+
+// ParseDefinitions 解析 config.json 中的 probes 数组
+func ParseDefinitions(raw any) ([]Definition, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("probes 配置错误: %w", err)
+	}
+	var defs []Definition
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return nil, fmt.Errorf("probes 配置错误: %w", err)
+	}
+	for i, d := range defs {
+		if d.URL == "" {
+			return nil, fmt.Errorf("probes[%d] 缺少 url", i)
+		}
+		if d.Method == "" {
+			defs[i].Method = "GET"
+		}
+		if d.Name == "" {
+			defs[i].Name = fmt.Sprintf("probes[%d]", i)
+		}
+		for j, rule := range d.Rules {
+			v, ok := model.ParseVerdict(string(rule.Result))
+			if !ok {
+				return nil, fmt.Errorf("probes[%d].rules[%d] 未知 result: %q", i, j, rule.Result)
+			}
+			defs[i].Rules[j].Result = v
+			if rule.Path != "" && !validOp(rule.Op) {
+				return nil, fmt.Errorf("probes[%d].rules[%d] 未知 op: %q", i, j, rule.Op)
+			}
+		}
+	}
+	return defs, nil
+}
+
+func (d Definition) Payload(authIndex string, vars map[string]string) map[string]any {
+	header := make(map[string]any, len(d.Header))
+	for k, v := range d.Header {
+		if v = expand(v, vars); v != "" {
+			header[k] = v
+		}
+	}
+	return mgmt.BuildAPICallPayload(authIndex, strings.ToUpper(d.Method), expand(d.URL, vars), header, expand(d.Body, vars))
+}
+
+// Classify 根据上游状态码和 api-call 响应中的 body 给出结论及原因
+func (d Definition) Classify(statusCode int, data map[string]any) (model.Verdict, string) {
+	var body any
+	bodyParsed := false
+	for _, rule := range d.Rules {
+		if len(rule.StatusCodes) > 0 && !containsInt(rule.StatusCodes, statusCode) {
+			continue
+		}
+		if rule.Path != "" {
+			if !bodyParsed {
+				body = parseBody(data)
+				bodyParsed = true
+			}
+			v, found := lookupPath(body, rule.Path)
+			if !compare(rule.Op, v, found, rule.Value) {
+				continue
+			}
+		}
+		return rule.Result, rule.describe(statusCode)
+	}
+	v := model.VerdictForStatus(statusCode)
+	if v == model.VerdictHealthy {
+		return v, ""
+	}
+	return v, fmt.Sprintf("HTTP %d", statusCode)
+}
+
+func (r Rule) describe(statusCode int) string {
+	switch {
+	case r.Reason != "":
+		return r.Reason
+	case r.Path != "":
+		return fmt.Sprintf("%s %s %v", r.Path, r.Op, r.Value)
+	default:
+		return fmt.Sprintf("HTTP %d", statusCode)
+	}
+}
+
+func expand(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	for k, v := range vars {
+		s = strings.ReplaceAll(s, "{{"+k+"}}", v)
+	}
+	return s
+}
+
+// parseBody api-call 响应中的 body 可能是 JSON 字符串或已解析的对象
+func parseBody(data map[string]any) any {
+	switch b := data["body"].(type) {
+	case string:
+		var v any
+		if err := json.Unmarshal([]byte(b), &v); err != nil {
+			return nil
+		}
+		return v
+	default:
+		return b
+	}
+}
+
+// lookupPath 按点分路径取值，数组下标用数字，例如 rate_limit.windows.0.reset_at
+func lookupPath(v any, path string) (any, bool) {
+	cur := v
+	for _, seg := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]any:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func compare(op string, v any, found bool, want any) bool {
+	switch op {
+	case "exists":
+		return found
+	case "missing":
+		return !found
+	}
+	if !found {
+		return false
+	}
+	switch op {
+	case "eq":
+		return equal(v, want)
+	case "ne":
+		return !equal(v, want)
+	case "contains":
+		s, ok := v.(string)
+		w, ok2 := want.(string)
+		return ok && ok2 && strings.Contains(s, w)
+	case "gt", "gte", "lt", "lte":
+		a, ok1 := toFloat(v)
+		b, ok2 := toFloat(want)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch op {
+		case "gt":
+			return a > b
+		case "gte":
+			return a >= b
+		case "lt":
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func validOp(op string) bool {
+	switch op {
+	case "eq", "ne", "gt", "gte", "lt", "lte", "exists", "missing", "contains":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package probe
+
+import (
+	"testing"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestRegistryLookupAndClassify(t *testing.T) {
+	defs, err := ParseDefinitions([]any{
+		map[string]any{
+			"name":   "claude",
+			"type":   "claude",
+			"url":    "https://api.anthropic.com/v1/models",
+			"header": map[string]any{"x-api-key": "$TOKEN$", "X-Account": "{{account}}"},
+			"rules": []any{
+				map[string]any{"status_codes": []any{float64(401), float64(403)}, "result": "unauthorized"},
+				map[string]any{"path": "error.type", "op": "eq", "value": "rate_limit_error", "result": "quota-exhausted"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry(defs...)
+
+	d, ok := reg.Lookup(model.AuthFile{"type": "Claude"})
+	if !ok || d.Name != "claude" {
+		t.Fatalf("expected claude definition, got %+v", d)
+	}
+	p := d.Payload("idx", map[string]string{"account": ""})
+	if p["method"] != "GET" {
+		t.Fatalf("method should default to GET: %+v", p)
+	}
+	if _, ok := p["header"].(map[string]any)["X-Account"]; ok {
+		t.Fatalf("empty header should be dropped: %+v", p)
+	}
+	if got, _ := d.Classify(403, nil); got != model.VerdictUnauthorized {
+		t.Fatalf("403 expected unauthorized, got %s", got)
+	}
+	if got, _ := d.Classify(429, map[string]any{"body": `{"error":{"type":"rate_limit_error"}}`}); got != model.VerdictQuotaExhausted {
+		t.Fatalf("expected quota-exhausted, got %s", got)
+	}
+
+	codex, ok := reg.Lookup(model.AuthFile{"typo": "codex"})
+	if !ok || codex.Name != "codex-wham-usage" {
+		t.Fatalf("expected builtin codex definition, got %+v", codex)
+	}
+	if got, reason := codex.Classify(200, map[string]any{"body": `{"usage":{"limit":0}}`}); got != model.VerdictQuotaExhausted || reason != "usage.limit=0" {
+		t.Fatalf("expected quota-exhausted, got %s", got)
+	}
+	if got, _ := codex.Classify(200, map[string]any{"body": `{"usage":{"limit":5}}`}); got != model.VerdictHealthy {
+		t.Fatalf("expected healthy, got %s", got)
+	}
+	if got, reason := codex.Classify(503, nil); got != model.VerdictUpstreamError || reason != "HTTP 503" {
+		t.Fatalf("expected upstream-error, got %s %q", got, reason)
+	}
+	if _, ok := reg.Lookup(model.AuthFile{"type": "gemini"}); ok {
+		t.Fatalf("gemini should have no definition")
+	}
+
+	if _, err := ParseDefinitions([]any{map[string]any{"url": "x", "rules": []any{map[string]any{"result": "bogus"}}}}); err == nil {
+		t.Fatalf("expected error for unknown result")
+	}
+}
@@ -0,0 +1,70 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+// Reverify 删除前重新探测单个账号，结果不写入历史。
+// 账号按 name 从最近一次拉取的列表中查找；尚未拉取过（如 --delete-from-output）时先拉取一次。
+func (s *Service) Reverify(ctx context.Context, opts *model.Options, c model.DeleteCandidate) (model.ProbeResult, error) {
+	item, err := s.lookupFile(ctx, c.Name)
+	if err != nil {
+		return model.ProbeResult{}, err
+	}
+	return s.ProbeFile(ctx, opts, item), nil
+}
+
+// ProbeFile 探测单个账号（含重试），结果不写入历史也不计入指标
+func (s *Service) ProbeFile(ctx context.Context, opts *model.Options, item model.AuthFile) model.ProbeResult {
+	started := time.Now()
+	r := s.probeOneWithRetry(ctx, item, opts)
+	r.LatencyMS = time.Since(started).Milliseconds()
+	return r
+}
+
+func (s *Service) setFiles(files []model.AuthFile) {
+	byName := make(map[string]model.AuthFile, len(files))
+	for _, f := range files {
+		if r := Identity(f); r.Name != "" {
+			byName[r.Name] = f
+		}
+	}
+	s.mu.Lock()
+	s.files = byName
+	s.mu.Unlock()
+}
+
+func (s *Service) lookupFile(ctx context.Context, name string) (model.AuthFile, error) {
+	if err := s.loadFiles(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("管理服务中不存在该账号")
+	}
+	return f, nil
+}
+
+// loadFiles 尚未拉取过账号列表时拉取一次；失败不缓存，下次调用时重试
+func (s *Service) loadFiles(ctx context.Context) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	s.mu.Lock()
+	loaded := s.files != nil
+	s.mu.Unlock()
+	if loaded {
+		return nil
+	}
+	files, err := s.Client.FetchAuthFiles(ctx)
+	if err != nil {
+		return err
+	}
+	s.setFiles(files)
+	return nil
+}
@@ -2,78 +2,147 @@ package probe
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"clean_codex_token/internal/filter"
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/logging"
+	"clean_codex_token/internal/metrics"
 	"clean_codex_token/internal/mgmt"
 	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/output"
+	"clean_codex_token/internal/ratelimit"
+	"clean_codex_token/internal/shutdown"
 )
 
-type Service struct {
-	Client *mgmt.Client
-}
+// errorThreshold 连续探测异常达到该次数后升级为删除
+const errorThreshold = 10
 
-func NewService(client *mgmt.Client) *Service {
-	return &Service{Client: client}
-}
+// defaultCoolingPeriod 额度耗尽但响应中没有重置时间、或重置时间已过时的冷却时长
+const defaultCoolingPeriod = 24 * time.Hour
 
-// errorCounter 用于统计每个 auth_index 的探测异常次数
-type errorCounter struct {
-	mu     sync.RWMutex
-	counts map[string]int
+// minCoolingPeriod 冷却的最短时长：重置时间只差几秒时也至少等这么久再复探
+const minCoolingPeriod = 10 * time.Minute
+
+type Service struct {
+	Client   *mgmt.Client
+	History  *history.Store
+	Registry *Registry
+	Policy   model.Policy
+	// Limiter 所有 worker 共享的探测限速器，nil 表示不限速
+	Limiter *ratelimit.Limiter
+	// Metrics 为 nil 时不采集指标
+	Metrics *metrics.Recorder
+	Logger  *slog.Logger
+	// Filter 在 target-type / provider 之外再按表达式筛选账号，nil 不筛选
+	Filter *filter.Expr
+	// Canaries 金丝雀账号（name、account 或 auth_index）：探测开始前与结束后都须为 healthy，本轮结论才可信
+	Canaries []string
+	// CanaryMode 金丝雀未通过时的处理方式，见 Canary*；默认 CanaryReport
+	CanaryMode string
+	// CoolingCycles 额度耗尽的账号冷却到额度重置时间再复探，经历该次数重置后仍耗尽才按 Policy 处置；0 不冷却
+	CoolingCycles int
+
+	mu sync.Mutex
+	// files 最近一次拉取的账号列表（按 name），供 Reverify 使用
+	files map[string]model.AuthFile
+	// loadMu 串行化 Reverify 时的账号列表拉取
+	loadMu sync.Mutex
 }
 
-func newErrorCounter() *errorCounter {
-	return &errorCounter{counts: make(map[string]int)}
+// Result 一轮探测的完整结果
+type Result struct {
+	Total   int
+	Matched int
+	// Accounts 全部已完成探测的账号（按名称排序），含正常账号
+	Accounts []model.ProbeResult
+	// Invalid 处置方式不为 keep 的账号，即导出到 --output 的内容
+	Invalid []model.ProbeResult
+	// Canary 非 nil 表示金丝雀账号未通过，本轮结论不可信，不应据此处置账号
+	Canary *CanaryError
+	// CanaryKeys 本轮作为金丝雀探测的文件（auth_index，缺少时为 name）
+	CanaryKeys []string
+	// Capacity 健康账号的额度概况
+	Capacity model.Capacity
 }
 
-func (ec *errorCounter) Increment(authIndex string) int {
-	ec.mu.Lock()
-	defer ec.mu.Unlock()
-	ec.counts[authIndex]++
-	return ec.counts[authIndex]
+func NewService(client *mgmt.Client, store *history.Store) *Service {
+	if store == nil {
+		store = history.NewMemory()
+	}
+	return &Service{Client: client, History: store, Registry: NewRegistry(), Policy: model.DefaultPolicy(), Logger: logging.Discard()}
 }
 
-func (s *Service) Run(ctx context.Context, opts *model.Options, progress func(string)) ([]model.ProbeResult, error) {
+func (s *Service) Run(ctx context.Context, opts *model.Options) (*Result, error) {
+	log := s.Logger
 	files, err := s.Client.FetchAuthFiles(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	matchCandidate := func(f model.AuthFile) bool {
-		if strings.ToLower(mgmt.GetItemType(f)) != strings.ToLower(opts.TargetType) {
-			return false
-		}
-		if opts.Provider != "" {
-			p, _ := f["provider"].(string)
-			if strings.ToLower(p) != strings.ToLower(opts.Provider) {
-				return false
-			}
-		}
-		return true
-	}
+	s.setFiles(files)
 
 	candidateCount := 0
 	for _, f := range files {
-		if matchCandidate(f) {
+		if s.Match(opts, f) {
 			candidateCount++
 		}
 	}
+	if err := s.checkDefinitions(opts, files); err != nil {
+		return nil, err
+	}
 
-	progress(fmt.Sprintf("总账号数: %d", len(files)))
-	progress(fmt.Sprintf("符合过滤条件账号数: %d", candidateCount))
-	progress(fmt.Sprintf("异步检测并发: workers=%d, timeout=%ds, retries=%d", opts.Workers, opts.Timeout, opts.Retries))
+	s.Metrics.SetAccounts(len(files), candidateCount)
+	log.Info(fmt.Sprintf("总账号数: %d", len(files)), "total", len(files))
+	log.Info(fmt.Sprintf("符合过滤条件账号数: %d", candidateCount), "matched", candidateCount, "target_type", opts.TargetType, "provider", opts.Provider, "filter", s.Filter.String())
+	log.Info(fmt.Sprintf("异步检测并发: workers=%d, timeout=%ds, retries=%d, probe-rps=%g", opts.Workers, opts.Timeout, opts.Retries, opts.ProbeRPS),
+		"workers", opts.Workers, "timeout", opts.Timeout, "retries", opts.Retries, "probe_rps", opts.ProbeRPS)
+
+	// 金丝雀先于其他账号探测；abort 模式下未通过时不覆盖上一轮的 output
+	var canaries []model.AuthFile
+	var canaryResults []model.ProbeResult
+	var canaryErr *CanaryError
+	if len(s.Canaries) > 0 {
+		canaries, canaryErr = s.findCanaries(files)
+		if canaryErr == nil {
+			canaryResults, canaryErr = s.probeCanaries(ctx, canaries, opts, "pre")
+		}
+		if canaryErr != nil {
+			log.Error(canaryErr.Error(), "canary", canaryErr.Name, "phase", canaryErr.Phase, "reason", canaryErr.Reason)
+			if s.CanaryMode == CanaryAbort {
+				return &Result{Total: len(files), Matched: candidateCount, Accounts: []model.ProbeResult{}, Invalid: []model.ProbeResult{}, Canary: canaryErr}, canaryErr
+			}
+			log.Warn("已降级为仅报告：继续探测并导出结果，本轮不处置任何账号")
+		} else {
+			log.Info(fmt.Sprintf("金丝雀账号探测正常: %d 个", len(canaries)), "canaries", len(canaries))
+		}
+	}
 
+	chosen := canaryKeys(canaries)
+
+	export, err := output.NewResultWriter(opts.Output, opts.OutputFormat, &output.Meta{
+		Tool:        output.Tool,
+		Version:     model.Version,
+		GeneratedAt: time.Now(),
+		BaseURL:     s.Client.BaseURL,
+		TargetType:  opts.TargetType,
+		Provider:    opts.Provider,
+		Filter:      s.Filter.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
 	if candidateCount == 0 {
-		if err := writeJSON(opts.Output, []model.ProbeResult{}); err != nil {
+		if err := export.Close(); err != nil {
 			return nil, err
 		}
-		progress(fmt.Sprintf("已导出: %s", opts.Output))
-		return []model.ProbeResult{}, nil
+		log.Info(fmt.Sprintf("已导出: %s", opts.Output), "output", opts.Output, "count", 0)
+		return &Result{Total: len(files), Accounts: []model.ProbeResult{}, Invalid: []model.ProbeResult{}, Canary: canaryErr, CanaryKeys: chosen}, nil
 	}
 
 	workers := opts.Workers
@@ -85,29 +154,73 @@ func (s *Service) Run(ctx context.Context, opts *model.Options, progress func(st
 	}
 	if workers > 64 {
 		workers = 64
-		progress("workers 过大，已自动限制为 64 以降低 CPU/内存压力")
+		log.Warn("workers 过大，已自动限制为 64 以降低 CPU/内存压力", "workers", opts.Workers)
+	}
+
+	// 配置了金丝雀时结论先写入历史副本，开始前与结束后的金丝雀都通过才并入历史
+	store := s.History
+	if len(s.Canaries) > 0 {
+		store = s.History.Stage()
 	}
 
 	taskCh := make(chan model.AuthFile, workers*2)
 	resultCh := make(chan model.ProbeResult, workers*2)
 	var wg sync.WaitGroup
 
-	ec := newErrorCounter()
-
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for item := range taskCh {
-				resultCh <- s.probeOneWithRetry(ctx, item, opts, ec)
+				if shutdown.Interrupted(ctx) {
+					// 已排队但未开始的任务直接丢弃
+					continue
+				}
+				started := time.Now()
+				r := s.probeOneWithRetry(ctx, item, opts)
+				r.LatencyMS = time.Since(started).Milliseconds()
+				if ctx.Err() != nil {
+					// 排空超时被强制取消的请求不计入结果和历史
+					continue
+				}
+				s.recordOutcome(store, &r)
+				s.Metrics.ObserveProbe(string(r.Verdict), r.Type, r.Provider, time.Duration(r.LatencyMS)*time.Millisecond)
+				resultCh <- r
 			}
 		}()
 	}
 
+	stopping := shutdown.Stopping(ctx)
+	skip := make(map[string]struct{}, len(chosen))
+	for _, k := range chosen {
+		skip[k] = struct{}{}
+	}
+	cooling := 0
 	go func() {
+	dispatch:
 		for _, f := range files {
-			if matchCandidate(f) {
-				taskCh <- f
+			if !s.Match(opts, f) {
+				continue
+			}
+			id := Identity(f)
+			if _, ok := skip[history.Key(id.Name, id.AuthIndex)]; ok {
+				// 金丝雀已在开始前探测过
+				continue
+			}
+			if r, ok := s.cooling(f, time.Now()); ok {
+				// 冷却中的账号等额度重置后再探测
+				cooling++
+				select {
+				case resultCh <- r:
+					continue
+				case <-stopping:
+					break dispatch
+				}
+			}
+			select {
+			case taskCh <- f:
+			case <-stopping:
+				break dispatch
 			}
 		}
 		close(taskCh)
@@ -115,54 +228,146 @@ func (s *Service) Run(ctx context.Context, opts *model.Options, progress func(st
 		close(resultCh)
 	}()
 
+	all := make([]model.ProbeResult, 0, candidateCount)
 	invalid := make([]model.ProbeResult, 0)
-	invalidByError := 0
-	invalidByLimit := 0
-	failed := 0
+	counts := make(map[model.Verdict]int, len(model.Verdicts))
 	done := 0
 	nextReport := 100
-	for r := range resultCh {
+	var exportErr error
+	collect := func(r model.ProbeResult) {
 		done++
-		if r.Invalid401 {
+		all = append(all, r)
+		counts[r.Verdict]++
+		log.Debug(fmt.Sprintf("[%s] %s | latency=%dms", r.Verdict, r.Name, r.LatencyMS), resultAttrs(r)...)
+		// 每个账号只产生一条结果，按处置方式决定是否导出
+		if r.Action != model.PolicyKeep {
 			invalid = append(invalid, r)
+			if err := export.Add(r); err != nil && exportErr == nil {
+				exportErr = err
+			}
 		}
-		if r.InvalidByError {
-			invalid = append(invalid, r)
-			invalidByError++
+		if done >= nextReport || done == candidateCount {
+			log.Info(fmt.Sprintf("检测进度: %d/%d", done, candidateCount), "done", done, "matched", candidateCount)
+			nextReport += 100
 		}
-		if r.InvalidByLimit {
-			invalid = append(invalid, r)
-			invalidByLimit++
+	}
+	for i, r := range canaryResults {
+		if s.Match(opts, canaries[i]) {
+			s.recordOutcome(store, &r)
+			s.Metrics.ObserveProbe(string(r.Verdict), r.Type, r.Provider, time.Duration(r.LatencyMS)*time.Millisecond)
+			collect(r)
 		}
-		if r.Error != "" && !r.InvalidByError {
-			failed++
+	}
+	for r := range resultCh {
+		collect(r)
+	}
+
+	interrupted := shutdown.Interrupted(ctx)
+	if len(canaries) > 0 && canaryErr == nil && !interrupted {
+		// 复查金丝雀，发现探测过程中上游退化；复查结果不写入历史
+		if _, canaryErr = s.probeCanaries(ctx, canaries, opts, "post"); canaryErr != nil {
+			log.Error(canaryErr.Error(), "canary", canaryErr.Name, "phase", canaryErr.Phase, "reason", canaryErr.Reason)
+		} else {
+			log.Info("金丝雀账号复查正常", "canaries", len(canaries))
 		}
-		if done >= nextReport || done == candidateCount {
-			progress(fmt.Sprintf("检测进度: %d/%d", done, candidateCount))
-			nextReport += 100
+	}
+
+	if err := export.Close(); err != nil && exportErr == nil {
+		exportErr = err
+	}
+	if store != s.History {
+		if canaryErr == nil && !interrupted {
+			s.History.Commit(store)
+		} else {
+			log.Warn("金丝雀未通过或未完成复查，本轮结论不可信，不写入探测历史")
 		}
 	}
+	if err := s.History.Save(); err != nil {
+		return nil, err
+	}
 
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
 	sort.Slice(invalid, func(i, j int) bool { return invalid[i].Name < invalid[j].Name })
-	progress(fmt.Sprintf("探测完成: 401失效=%d，异常10次=%d，限额为0=%d，探测异常=%d", len(invalid)-invalidByError-invalidByLimit, invalidByError, invalidByLimit, failed))
+	summary := make([]string, 0, len(model.Verdicts))
+	runCounts := make(map[string]int, len(model.Verdicts))
+	for _, v := range model.Verdicts {
+		summary = append(summary, fmt.Sprintf("%s=%d", verdictLabel(v), counts[v]))
+		runCounts[string(v)] = counts[v]
+	}
+	s.Metrics.SetRunVerdicts(runCounts)
+	if interrupted {
+		log.Warn(fmt.Sprintf("探测已中断: 已完成 %d/%d", done, candidateCount), "done", done, "matched", candidateCount)
+	}
+	summaryAttrs := []any{"done", done, "invalid", len(invalid)}
+	for _, v := range model.Verdicts {
+		summaryAttrs = append(summaryAttrs, string(v), counts[v])
+	}
+	log.Info("探测完成: "+strings.Join(summary, "，"), summaryAttrs...)
+	if cooling > 0 {
+		log.Info(fmt.Sprintf("额度冷却中跳过探测: %d 个，额度重置后复探", cooling), "cooling", cooling)
+	}
+	capacity := model.SummarizeCapacity(all)
+	s.Metrics.SetCapacity(capacity.Headroom, capacity.AvgUsedPercent, capacity.NearLimit)
+	logCapacity(log, capacity)
 	for _, r := range invalid {
-		if r.InvalidByError {
-			progress(fmt.Sprintf("[ERR] %s | account=%s | auth_index=%s | error_count=%d", r.Name, r.Account, r.AuthIndex, r.ErrorCount))
-		} else if r.InvalidByLimit {
-			progress(fmt.Sprintf("[LIMIT] %s | account=%s | auth_index=%s | limit=0", r.Name, r.Account, r.AuthIndex))
-		} else {
-			progress(fmt.Sprintf("[401] %s | account=%s | auth_index=%s", r.Name, r.Account, r.AuthIndex))
+		log.Info(fmt.Sprintf("[%s] %s | account=%s | auth_index=%s | reason=%s | action=%s", r.Verdict, r.Name, r.Account, r.AuthIndex, r.Reason, r.Action), resultAttrs(r)...)
+	}
+
+	if exportErr != nil {
+		return nil, exportErr
+	}
+	log.Info(fmt.Sprintf("已导出: %s", opts.Output), "output", opts.Output, "format", opts.OutputFormat, "count", len(invalid))
+	res := &Result{Total: len(files), Matched: candidateCount, Accounts: all, Invalid: invalid, Canary: canaryErr, CanaryKeys: chosen, Capacity: capacity}
+	if interrupted {
+		return res, shutdown.ErrInterrupted
+	}
+	if canaryErr != nil && s.CanaryMode == CanaryAbort {
+		return res, canaryErr
+	}
+	return res, nil
+}
+
+// checkDefinitions 范围内有账号没有匹配的探测定义时拒绝本轮：这些账号无法判定，
+// 探测结论只会是 unknown，不应计入历史或导出
+func (s *Service) checkDefinitions(opts *model.Options, files []model.AuthFile) error {
+	missing := map[string]int{}
+	for _, f := range files {
+		if !s.Match(opts, f) {
+			continue
 		}
+		if _, ok := s.Registry.Lookup(f); !ok {
+			missing[fmt.Sprintf("type=%s provider=%s", mgmt.GetItemType(f), str(f["provider"]))]++
+		}
+	}
+	if len(missing) == 0 {
+		return nil
 	}
+	kinds := make([]string, 0, len(missing))
+	total := 0
+	for k, n := range missing {
+		kinds = append(kinds, fmt.Sprintf("%s（%d 个）", k, n))
+		total += n
+	}
+	sort.Strings(kinds)
+	return fmt.Errorf("%d 个账号没有匹配的探测定义: %s；请在 config.json 的 probes 中添加定义，或用 --target-type / --provider / --filter 缩小范围", total, strings.Join(kinds, "，"))
+}
 
-	if err := writeJSON(opts.Output, invalid); err != nil {
-		return nil, err
+// Match 账号是否符合 --target-type / --provider / --filter；target-type 为空时不按类型过滤
+func (s *Service) Match(opts *model.Options, f model.AuthFile) bool {
+	if opts.TargetType != "" && strings.ToLower(mgmt.GetItemType(f)) != strings.ToLower(opts.TargetType) {
+		return false
+	}
+	if opts.Provider != "" {
+		p, _ := f["provider"].(string)
+		if strings.ToLower(p) != strings.ToLower(opts.Provider) {
+			return false
+		}
 	}
-	progress(fmt.Sprintf("已导出: %s", opts.Output))
-	return invalid, nil
+	return s.Filter.Match(f)
 }
 
-func (s *Service) probeOneWithRetry(ctx context.Context, item model.AuthFile, opts *model.Options, ec *errorCounter) model.ProbeResult {
+// Identity 只含账号标识字段的初始结果
+func Identity(item model.AuthFile) model.ProbeResult {
 	authIndex, _ := item["auth_index"].(string)
 	name, _ := item["name"].(string)
 	if name == "" {
@@ -172,74 +377,214 @@ func (s *Service) probeOneWithRetry(ctx context.Context, item model.AuthFile, op
 	if account == "" {
 		account, _ = item["email"].(string)
 	}
-
-	result := model.ProbeResult{
+	return model.ProbeResult{
 		Name:      name,
 		Account:   account,
 		AuthIndex: authIndex,
 		Type:      mgmt.GetItemType(item),
 		Provider:  str(item["provider"]),
+		Verdict:   model.VerdictUnknown,
 	}
+}
+
+func (s *Service) probeOneWithRetry(ctx context.Context, item model.AuthFile, opts *model.Options) model.ProbeResult {
+	result := Identity(item)
+	name, account, authIndex := result.Name, result.Account, result.AuthIndex
 	if authIndex == "" {
 		result.Error = "missing auth_index"
+		result.Reason = result.Error
+		return result
+	}
+
+	def, ok := s.Registry.Lookup(item)
+	if !ok {
+		result.Error = fmt.Sprintf("no probe definition for type=%s provider=%s", result.Type, result.Provider)
+		result.Reason = result.Error
 		return result
 	}
+	result.Probe = def.Name
 
 	chatID := mgmt.ExtractChatgptAccountID(item)
 	if chatID == "" {
 		chatID = opts.ChatgptAccountID
 	}
-	payload := mgmt.BuildProbePayload(authIndex, opts.UserAgent, chatID)
-
+	payload := def.Payload(authIndex, map[string]string{
+		"name":               name,
+		"account":            account,
+		"auth_index":         authIndex,
+		"user_agent":         opts.UserAgent,
+		"chatgpt_account_id": chatID,
+	})
+
+	var retryAfter time.Duration
 	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, backoffDelay(attempt, retryAfter)); err != nil {
+				return result
+			}
+		}
+		if err := s.Limiter.Wait(ctx); err != nil {
+			result.Verdict = model.VerdictTransportError
+			result.Error = err.Error()
+			result.Reason = result.Error
+			return result
+		}
 		_, data, err := s.Client.ProbeOne(ctx, payload)
 		if err != nil {
+			result.Verdict = model.VerdictTransportError
 			result.Error = err.Error()
-			if attempt >= opts.Retries {
-				// 重试耗尽，增加异常计数
-				errCount := ec.Increment(authIndex)
-				result.ErrorCount = errCount
-				if errCount >= 10 {
-					result.InvalidByError = true
-				}
-				return result
+			result.Reason = result.Error
+			retryAfter = 0
+			var httpErr *mgmt.HTTPError
+			if errors.As(err, &httpErr) {
+				retryAfter = httpErr.RetryAfter
 			}
 			continue
 		}
 		sc, ok := asInt(data["status_code"])
 		if !ok {
 			result.StatusCode = nil
-			result.Invalid401 = false
+			result.Verdict = model.VerdictUnknown
 			result.Error = "missing status_code in api-call response"
-			// 响应异常也计入错误次数
-			errCount := ec.Increment(authIndex)
-			result.ErrorCount = errCount
-			if errCount >= 10 {
-				result.InvalidByError = true
-			}
+			result.Reason = result.Error
 			return result
 		}
 		result.StatusCode = &sc
-		result.Invalid401 = sc == 401
 		result.Error = ""
-
-		// 检查限额是否为 0
-		if isLimitZero(data) {
-			result.InvalidByLimit = true
+		result.Verdict, result.Reason = def.Classify(sc, data)
+		result.Usage = def.ExtractUsage(data, time.Now())
+		if sc == 429 || sc == 503 {
+			// 上游限流/过载，按上游 Retry-After 退避后重试
+			retryAfter = mgmt.UpstreamRetryAfter(data)
+			continue
 		}
-
 		return result
 	}
 
 	return result
 }
 
-func writeJSON(path string, v any) error {
-	b, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return err
+// recordOutcome 将结论写入 store，按 Policy 得出处置方式；连续探测异常达到阈值时升级为删除
+func (s *Service) recordOutcome(store *history.Store, r *model.ProbeResult) {
+	if r.Probe == "" {
+		// 缺少 auth_index 或没有探测定义，未实际探测：不写入历史、不升级、不处置
+		r.Action = model.PolicyKeep
+		return
+	}
+	r.Action = s.Policy.ActionFor(r.Verdict)
+	if r.AuthIndex == "" {
+		return
+	}
+	rec := store.Record(r.Name, r.AuthIndex, history.Event{At: time.Now(), Outcome: r.Verdict, StatusCode: r.StatusCode, Error: r.Error})
+
+	r.ConsecutiveFailures = rec.ConsecutiveFailures
+	r.FirstFailingAt = rec.FirstFailingAt
+	r.LastOKAt = rec.LastOKAt
+	if r.Verdict.IsError() {
+		// 连续异常次数跨运行累计
+		r.ErrorCount = rec.ConsecutiveErrors
+		if rec.ConsecutiveErrors >= errorThreshold && r.Action == model.PolicyKeep {
+			r.Action = model.PolicyDelete
+			r.Reason = fmt.Sprintf("连续异常 %d 次: %s", rec.ConsecutiveErrors, r.Reason)
+		}
+	}
+	if r.Verdict == model.VerdictQuotaExhausted && r.Action != model.PolicyKeep && s.CoolingCycles > 0 {
+		s.cool(store, r)
+	}
+}
+
+// cool 额度耗尽的账号先冷却到额度重置时间，经历 CoolingCycles 次重置后仍耗尽才保留 Policy 给出的处置方式
+func (s *Service) cool(store *history.Store, r *model.ProbeResult) {
+	now := time.Now()
+	until := coolingUntil(r.Usage.ResetAt(), now)
+	rec := store.Cool(r.Name, r.AuthIndex, until, now)
+	if rec.CoolingResets >= s.CoolingCycles {
+		r.Reason = fmt.Sprintf("额度重置 %d 次后仍耗尽: %s", rec.CoolingResets, r.Reason)
+		return
+	}
+	r.Action = model.PolicyKeep
+	r.CoolingUntil = rec.CoolingUntil
+	r.Reason = fmt.Sprintf("%s | 冷却至 %s（已重置 %d/%d 次）", r.Reason, until.Local().Format("2006-01-02 15:04"), rec.CoolingResets, s.CoolingCycles)
+}
+
+// coolingUntil 冷却截止时间：响应中没有重置时间或重置时间已过（上游数据过期）时为 defaultCoolingPeriod 之后，
+// 否则为重置时间，且至少 minCoolingPeriod 之后
+func coolingUntil(resetAt *time.Time, now time.Time) time.Time {
+	if resetAt == nil || !resetAt.After(now) {
+		return now.Add(defaultCoolingPeriod)
+	}
+	if floor := now.Add(minCoolingPeriod); resetAt.Before(floor) {
+		return floor
+	}
+	return *resetAt
+}
+
+// cooling 账号仍在冷却期内时返回不经探测的结果：沿用额度耗尽的结论，处置方式为 keep
+func (s *Service) cooling(f model.AuthFile, now time.Time) (model.ProbeResult, bool) {
+	if s.CoolingCycles <= 0 {
+		return model.ProbeResult{}, false
+	}
+	r := Identity(f)
+	if r.AuthIndex == "" {
+		return r, false
+	}
+	rec, ok := s.History.Get(history.Key(r.Name, r.AuthIndex))
+	if !ok || rec.CoolingUntil == nil || !now.Before(*rec.CoolingUntil) || rec.CoolingResets >= s.CoolingCycles {
+		return r, false
+	}
+	r.Verdict = model.VerdictQuotaExhausted
+	r.Action = model.PolicyKeep
+	r.CoolingUntil = rec.CoolingUntil
+	r.ConsecutiveFailures = rec.ConsecutiveFailures
+	r.FirstFailingAt = rec.FirstFailingAt
+	r.LastOKAt = rec.LastOKAt
+	r.Reason = fmt.Sprintf("额度冷却中，%s 重置后复探（已重置 %d/%d 次）", rec.CoolingUntil.Local().Format("2006-01-02 15:04"), rec.CoolingResets, s.CoolingCycles)
+	return r, true
+}
+
+// resultAttrs 单个账号探测结果的结构化字段
+func resultAttrs(r model.ProbeResult) []any {
+	attrs := []any{
+		"name", r.Name,
+		"account", r.Account,
+		"auth_index", r.AuthIndex,
+		"type", r.Type,
+		"provider", r.Provider,
+		"verdict", string(r.Verdict),
+		"action", r.Action,
+		"latency_ms", r.LatencyMS,
+	}
+	if r.StatusCode != nil {
+		attrs = append(attrs, "status_code", *r.StatusCode)
+	}
+	if r.Reason != "" {
+		attrs = append(attrs, "reason", r.Reason)
+	}
+	if r.Error != "" {
+		attrs = append(attrs, "error", r.Error)
+	}
+	return attrs
+}
+
+func verdictLabel(v model.Verdict) string {
+	switch v {
+	case model.VerdictHealthy:
+		return "正常"
+	case model.VerdictUnauthorized:
+		return "401失效"
+	case model.VerdictForbidden:
+		return "403禁止"
+	case model.VerdictRateLimited:
+		return "限流"
+	case model.VerdictQuotaExhausted:
+		return "限额耗尽"
+	case model.VerdictUpstreamError:
+		return "上游异常"
+	case model.VerdictTransportError:
+		return "探测异常"
+	default:
+		return "未知"
 	}
-	return os.WriteFile(path, b, 0o644)
 }
 
 func asInt(v any) (int, bool) {
@@ -257,31 +602,3 @@ func str(v any) string {
 	s, _ := v.(string)
 	return s
 }
-
-// isLimitZero 检查响应数据中的限额是否为 0
-// 探测接口返回的数据结构: {"status_code": 200, "body": "..."}
-// body 是 JSON 字符串，包含 usage 信息
-func isLimitZero(data map[string]any) bool {
-	bodyStr, ok := data["body"].(string)
-	if !ok {
-		return false
-	}
-
-	var body map[string]any
-	if err := json.Unmarshal([]byte(bodyStr), &body); err != nil {
-		return false
-	}
-
-	// 检查 usage 对象中的 limit 字段
-	usage, ok := body["usage"].(map[string]any)
-	if !ok {
-		return false
-	}
-
-	limit, ok := asInt(usage["limit"])
-	if !ok {
-		return false
-	}
-
-	return limit == 0
-}
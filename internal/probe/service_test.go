@@ -0,0 +1,99 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/history"
+	"clean_codex_token/internal/mgmt"
+	"clean_codex_token/internal/model"
+)
+
+func TestRecordOutcomeSkipsUnprobed(t *testing.T) {
+	s := NewService(nil, nil)
+	// 即使 policy 要求删除 unknown，未实际探测的账号也不能被处置
+	if err := s.Policy.Set(string(model.VerdictUnknown), model.PolicyDelete); err != nil {
+		t.Fatal(err)
+	}
+	item := model.AuthFile{"name": "claude-1", "auth_index": "idx-claude", "type": "claude", "provider": "anthropic"}
+	for i := 0; i < errorThreshold+2; i++ {
+		r := s.probeOneWithRetry(context.Background(), item, &model.Options{})
+		s.recordOutcome(s.History, &r)
+		if r.Verdict != model.VerdictUnknown || r.Action != model.PolicyKeep {
+			t.Fatalf("run %d: unprobed account must be kept, got %+v", i+1, r)
+		}
+	}
+	if rec, ok := s.History.Get(history.Key("claude-1", "idx-claude")); ok {
+		t.Fatalf("unprobed account must not be recorded: %+v", rec)
+	}
+}
+
+func TestUnexpectedStatusNeverEscalates(t *testing.T) {
+	// 上游故障持续返回 400：结论为 unknown，但不是探测异常，不能在连续 10 轮后升级为删除
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"status_code": 400, "body": "{}"})
+	}))
+	defer srv.Close()
+
+	s := NewService(mgmt.NewClient(srv.URL, "t", 5), nil)
+	item := model.AuthFile{"name": "a.json", "auth_index": "idx-a", "type": "codex", "provider": "openai"}
+	for i := 0; i < errorThreshold+2; i++ {
+		r := s.probeOneWithRetry(context.Background(), item, &model.Options{})
+		s.recordOutcome(s.History, &r)
+		if r.Verdict != model.VerdictUnknown || r.Action != model.PolicyKeep || r.ErrorCount != 0 {
+			t.Fatalf("run %d: unexpected status must be kept without escalation, got %+v", i+1, r)
+		}
+	}
+	if rec, _ := s.History.Get("idx-a"); rec.ConsecutiveErrors != 0 || rec.ConsecutiveFailures != errorThreshold+2 {
+		t.Fatalf("unexpected counters: %+v", rec)
+	}
+}
+
+func TestLookupFileRetriesAfterFetchError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"files": []map[string]any{{"name": "a.json", "auth_index": "idx-a"}}})
+	}))
+	defer srv.Close()
+
+	s := NewService(mgmt.NewClient(srv.URL, "t", 5), nil)
+	if _, err := s.lookupFile(context.Background(), "a.json"); err == nil {
+		t.Fatal("expected the first fetch to fail")
+	}
+	// 一次失败不能让之后的复查都失败
+	if f, err := s.lookupFile(context.Background(), "a.json"); err != nil || f["auth_index"] != "idx-a" {
+		t.Fatalf("second lookup should refetch, got %v %v", f, err)
+	}
+	if _, err := s.lookupFile(context.Background(), "a.json"); err != nil || calls.Load() != 2 {
+		t.Fatalf("loaded list should be reused, calls=%d err=%v", calls.Load(), err)
+	}
+}
+
+func TestCoolingUntilClampsResetAt(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { v := now.Add(d); return &v }
+	cases := []struct {
+		resetAt *time.Time
+		want    time.Time
+	}{
+		{nil, now.Add(defaultCoolingPeriod)},
+		{at(-time.Hour), now.Add(defaultCoolingPeriod)},
+		{at(0), now.Add(defaultCoolingPeriod)},
+		{at(30 * time.Second), now.Add(minCoolingPeriod)},
+		{at(5 * time.Hour), now.Add(5 * time.Hour)},
+	}
+	for _, c := range cases {
+		if got := coolingUntil(c.resetAt, now); !got.Equal(c.want) {
+			t.Errorf("coolingUntil(%v) = %v, want %v", c.resetAt, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package probe
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+// UsageFields 额度字段在响应 body 中的路径（写法同 Rule.Path），未配置的字段不提取。
+// Primary / Secondary 指向限额窗口对象，读取其中的 used_percent、limit_window_seconds、
+// reset_at（Unix 秒 / 毫秒或 RFC3339）或 reset_after_seconds
+type UsageFields struct {
+	Plan         string `json:"plan"`
+	Used         string `json:"used"`
+	Limit        string `json:"limit"`
+	LimitReached string `json:"limit_reached"`
+	Primary      string `json:"primary_window"`
+	Secondary    string `json:"secondary_window"`
+}
+
+// ExtractUsage 按 d.Usage 从 api-call 响应中提取额度信息；未配置或响应中没有任何额度字段时返回 nil
+func (d Definition) ExtractUsage(data map[string]any, now time.Time) *model.Usage {
+	f := d.Usage
+	if f == nil {
+		return nil
+	}
+	body := parseBody(data)
+	if body == nil {
+		return nil
+	}
+	u := &model.Usage{}
+	found := false
+	if v, ok := lookupString(body, f.Plan); ok {
+		u.PlanType, found = v, true
+	}
+	if v, ok := lookupFloat(body, f.Used); ok {
+		u.Used, found = &v, true
+	}
+	if v, ok := lookupFloat(body, f.Limit); ok {
+		u.Limit, found = &v, true
+	}
+	if f.LimitReached != "" {
+		if v, ok := lookupPath(body, f.LimitReached); ok {
+			b, _ := v.(bool)
+			u.LimitReached, found = b, true
+		}
+	}
+	if w := extractWindow(body, f.Primary, now); w != nil {
+		u.Primary, found = w, true
+	}
+	if w := extractWindow(body, f.Secondary, now); w != nil {
+		u.Secondary, found = w, true
+	}
+	if !found {
+		return nil
+	}
+	u.Normalize()
+	return u
+}
+
+func extractWindow(body any, path string, now time.Time) *model.UsageWindow {
+	if path == "" {
+		return nil
+	}
+	v, ok := lookupPath(body, path)
+	if !ok {
+		return nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	w := &model.UsageWindow{}
+	if p, ok := toFloat(obj["used_percent"]); ok {
+		w.UsedPercent = &p
+	}
+	if s, ok := toFloat(obj["limit_window_seconds"]); ok {
+		w.WindowSeconds = int64(s)
+	}
+	if at, ok := parseResetAt(obj["reset_at"]); ok {
+		w.ResetAt = &at
+	} else if s, ok := toFloat(obj["reset_after_seconds"]); ok {
+		at := now.Add(time.Duration(s * float64(time.Second))).Truncate(time.Second)
+		w.ResetAt = &at
+	}
+	if w.UsedPercent == nil && w.WindowSeconds == 0 && w.ResetAt == nil {
+		return nil
+	}
+	return w
+}
+
+func parseResetAt(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case float64:
+		if x > 1e12 {
+			return time.UnixMilli(int64(x)), true
+		}
+		return time.Unix(int64(x), 0), true
+	case string:
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(x)); err == nil {
+			return t, true
+		}
+		if n, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+			return parseResetAt(n)
+		}
+	}
+	return time.Time{}, false
+}
+
+func lookupString(body any, path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	v, ok := lookupPath(body, path)
+	s, isStr := v.(string)
+	return s, ok && isStr && s != ""
+}
+
+func lookupFloat(body any, path string) (float64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	v, ok := lookupPath(body, path)
+	if !ok {
+		return 0, false
+	}
+	return toFloat(v)
+}
+
+// logCapacity 输出账号池额度概况；没有任何额度信息时只输出健康账号数
+func logCapacity(log *slog.Logger, c model.Capacity) {
+	attrs := []any{"healthy", c.Healthy, "with_usage", c.WithUsage, "avg_used_percent", round1(c.AvgUsedPercent), "headroom", round1(c.Headroom), "near_limit", c.NearLimit, "exhausted", c.Exhausted}
+	if c.WithUsage == 0 {
+		log.Info(fmt.Sprintf("额度概况: 健康账号 %d 个，无额度信息", c.Healthy), attrs...)
+		return
+	}
+	msg := fmt.Sprintf("额度概况: 健康账号 %d 个（%d 个有额度信息），平均已用 %.1f%%，剩余约 %.1f 个账号的额度，%d 个接近上限（≥%d%%）",
+		c.Healthy, c.WithUsage, c.AvgUsedPercent, c.Headroom, c.NearLimit, model.NearLimitPercent)
+	if c.Limit > 0 {
+		msg += fmt.Sprintf("，合计已用 %g/%g", c.Used, c.Limit)
+		attrs = append(attrs, "used", c.Used, "limit", c.Limit)
+	}
+	if len(c.Plans) > 0 {
+		plans := make([]string, 0, len(c.Plans))
+		for _, p := range c.PlanNames() {
+			plans = append(plans, fmt.Sprintf("%s=%d", p, c.Plans[p]))
+		}
+		msg += "，套餐: " + strings.Join(plans, ", ")
+		attrs = append(attrs, "plans", c.Plans)
+	}
+	if c.Exhausted > 0 && c.NextResetAt != nil {
+		msg += fmt.Sprintf("；额度耗尽 %d 个，最早 %s 恢复", c.Exhausted, c.NextResetAt.Local().Format("2006-01-02 15:04"))
+		attrs = append(attrs, "next_reset_at", *c.NextResetAt)
+	}
+	log.Info(msg, attrs...)
+}
+
+func round1(f float64) float64 { return math.Round(f*10) / 10 }
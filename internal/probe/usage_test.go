@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestExtractUsageCodex(t *testing.T) {
+	codex, _ := NewRegistry().Lookup(model.AuthFile{"type": "codex"})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := `{"plan_type":"plus","rate_limit":{"limit_reached":false,
+		"primary_window":{"used_percent":40,"limit_window_seconds":18000,"reset_after_seconds":600},
+		"secondary_window":{"used_percent":75.5,"limit_window_seconds":604800,"reset_at":1772445600}}}`
+
+	u := codex.ExtractUsage(map[string]any{"body": body}, now)
+	if u == nil || u.PlanType != "plus" || u.LimitReached {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if u.UsedPercent == nil || *u.UsedPercent != 75.5 {
+		t.Fatalf("used_percent should be the highest window: %+v", u.UsedPercent)
+	}
+	if u.Primary.WindowSeconds != 18000 || !u.Primary.ResetAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("unexpected primary window: %+v", u.Primary)
+	}
+	if !u.Secondary.ResetAt.Equal(time.Unix(1772445600, 0)) {
+		t.Fatalf("unexpected secondary reset: %v", u.Secondary.ResetAt)
+	}
+	// 没有用尽的窗口时取最早重置
+	if at := u.ResetAt(); !at.Equal(now.Add(10 * time.Minute)) {
+		t.Fatalf("unexpected reset: %v", at)
+	}
+
+	u = codex.ExtractUsage(map[string]any{"body": `{"usage":{"used":30,"limit":120}}`}, now)
+	if u == nil || *u.UsedPercent != 25 {
+		t.Fatalf("used/limit percent: %+v", u)
+	}
+	u = codex.ExtractUsage(map[string]any{"body": `{"usage":{"limit":0}}`}, now)
+	if u == nil || !u.LimitReached {
+		t.Fatalf("limit=0 should be limit reached: %+v", u)
+	}
+	if u := codex.ExtractUsage(map[string]any{"body": `{"other":1}`}, now); u != nil {
+		t.Fatalf("expected nil usage, got %+v", u)
+	}
+	if u := (Definition{}).ExtractUsage(map[string]any{"body": body}, now); u != nil {
+		t.Fatalf("definition without usage fields should not extract, got %+v", u)
+	}
+}
+
+func TestSummarizeCapacity(t *testing.T) {
+	pct := func(f float64) *float64 { return &f }
+	reset := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	results := []model.ProbeResult{
+		{Verdict: model.VerdictHealthy, Usage: &model.Usage{PlanType: "plus", UsedPercent: pct(20)}},
+		{Verdict: model.VerdictHealthy, Usage: &model.Usage{PlanType: "plus", UsedPercent: pct(95)}},
+		{Verdict: model.VerdictHealthy, Usage: &model.Usage{PlanType: "pro"}},
+		{Verdict: model.VerdictHealthy},
+		{Verdict: model.VerdictQuotaExhausted, Usage: &model.Usage{UsedPercent: pct(100), Primary: &model.UsageWindow{UsedPercent: pct(100), ResetAt: &reset}}},
+		{Verdict: model.VerdictUnauthorized, Usage: &model.Usage{UsedPercent: pct(0)}},
+	}
+	c := model.SummarizeCapacity(results)
+	if c.Healthy != 4 || c.WithUsage != 2 || c.NearLimit != 1 || c.Exhausted != 1 {
+		t.Fatalf("unexpected counts: %+v", c)
+	}
+	if c.AvgUsedPercent != 57.5 || c.Headroom < 0.849 || c.Headroom > 0.851 {
+		t.Fatalf("unexpected avg/headroom: %+v", c)
+	}
+	if c.Plans["plus"] != 2 || c.Plans["pro"] != 1 || c.PlanNames()[0] != "plus" {
+		t.Fatalf("unexpected plans: %+v", c.Plans)
+	}
+	if c.NextResetAt == nil || !c.NextResetAt.Equal(reset) {
+		t.Fatalf("unexpected next reset: %v", c.NextResetAt)
+	}
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterPacesRequests(t *testing.T) {
+	l := New(50, 1)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 首个令牌立即可用，其余 5 个按 20ms 间隔发放
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("limiter too fast: %s", elapsed)
+	}
+
+	// 桶初始满，第一次不等待；第二次需要等待时应返回 ctx 错误
+	slow := New(0.001, 1)
+	if err := slow.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := slow.Wait(ctx); err == nil {
+		t.Fatalf("expected context error")
+	}
+	if err := (*Limiter)(nil).Wait(context.Background()); err != nil {
+		t.Fatalf("nil limiter should not block: %v", err)
+	}
+}
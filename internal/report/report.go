@@ -0,0 +1,231 @@
+package report
+
+import (
+	"time"
+
+	"clean_codex_token/internal/model"
+	"clean_codex_token/internal/output"
+)
+
+// SchemaVersion 报告结构版本；字段只增不改，删除或改名字段时递增
+const SchemaVersion = 1
+
+const redacted = "<redacted>"
+
+// Report 一次运行（或 cron 的一轮）的完整记录，由 --report 导出
+type Report struct {
+	SchemaVersion int    `json:"schema_version"`
+	Mode          string `json:"mode"`
+	// Target 多目标模式下的目标名称
+	Target      string    `json:"target,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DurationMS  int64     `json:"duration_ms"`
+	Interrupted bool      `json:"interrupted"`
+	Error       string    `json:"error,omitempty"`
+	// Refused 删除被安全阈值拒绝时触发的规则（见 guard.Rule*）
+	Refused string  `json:"refused,omitempty"`
+	Options Options `json:"options"`
+	Counts  Counts  `json:"counts"`
+	// Capacity 健康账号的额度概况，仅探测模式有
+	Capacity *model.Capacity `json:"capacity,omitempty"`
+	// Accounts 本轮全部已完成探测的账号，含正常账号
+	Accounts []model.ProbeResult `json:"accounts"`
+	// Deletes 全部删除/禁用/隔离尝试，dry-run 时为计划
+	Deletes []model.DeleteResult `json:"deletes"`
+}
+
+// Options 本次运行使用的参数，token 已脱敏
+type Options struct {
+	BaseURL            string  `json:"base_url"`
+	Token              string  `json:"token"`
+	TargetType         string  `json:"target_type"`
+	Provider           string  `json:"provider,omitempty"`
+	Filter             string  `json:"filter,omitempty"`
+	Allow              string  `json:"allow,omitempty"`
+	Protect            string  `json:"protect,omitempty"`
+	Workers            int     `json:"workers"`
+	DeleteWorkers      int     `json:"delete_workers"`
+	Timeout            int     `json:"timeout"`
+	Retries            int     `json:"retries"`
+	ProbeRPS           float64 `json:"probe_rps"`
+	Output             string  `json:"output"`
+	History            string  `json:"history"`
+	Policy             string  `json:"policy"`
+	Strategy           string  `json:"strategy"`
+	QuarantineFailures int     `json:"quarantine_failures"`
+	QuarantineWindow   string  `json:"quarantine_window"`
+	BackupDir          string  `json:"backup_dir,omitempty"`
+	MaxDeleteRatio     float64 `json:"max_delete_ratio,omitempty"`
+	MaxDelete          int     `json:"max_delete,omitempty"`
+	MinKeep            int     `json:"min_keep,omitempty"`
+	Canaries           string  `json:"canaries,omitempty"`
+	CanaryMode         string  `json:"canary_mode,omitempty"`
+	Reverify           bool    `json:"reverify,omitempty"`
+	CoolingCycles      int     `json:"cooling_cycles"`
+	Delete             bool    `json:"delete"`
+	DeleteFromOutput   bool    `json:"delete_from_output"`
+	DryRun             bool    `json:"dry_run"`
+	Cron               string  `json:"cron,omitempty"`
+	CronTZ             string  `json:"cron_tz,omitempty"`
+	Listen             string  `json:"listen,omitempty"`
+}
+
+type Counts struct {
+	Total   int `json:"total"`
+	Matched int `json:"matched"`
+	Probed  int `json:"probed"`
+	Invalid int `json:"invalid"`
+	// Cooling 额度耗尽、冷却等待重置而暂不处置的账号数
+	Cooling int `json:"cooling"`
+	// Verdicts 各结论数量，包含数量为 0 的结论
+	Verdicts        map[model.Verdict]int `json:"verdicts"`
+	DeleteAttempted int                   `json:"delete_attempted"`
+	Deleted         int                   `json:"deleted"`
+	DeleteFailed    int                   `json:"delete_failed"`
+	Disabled        int                   `json:"disabled"`
+	DisableFailed   int                   `json:"disable_failed"`
+	Quarantined     int                   `json:"quarantined"`
+	Held            int                   `json:"held"`
+	Skipped         int                   `json:"skipped"`
+	Planned         int                   `json:"planned"`
+}
+
+// New 创建报告；返回值的方法对 nil 安全，未指定 --report 时直接传 nil
+func New(opts *model.Options, mode string, started time.Time) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		Mode:          mode,
+		StartedAt:     started,
+		Options:       NewOptions(opts),
+		Counts:        Counts{Verdicts: map[model.Verdict]int{}},
+		Accounts:      []model.ProbeResult{},
+		Deletes:       []model.DeleteResult{},
+	}
+}
+
+// NewOptions 提取需要记录的参数并脱敏 token
+func NewOptions(opts *model.Options) Options {
+	token := ""
+	if opts.Token != "" {
+		token = redacted
+	}
+	backupDir := opts.BackupDir
+	if opts.NoBackup {
+		backupDir = ""
+	}
+	return Options{
+		BaseURL:            opts.BaseURL,
+		Token:              token,
+		TargetType:         opts.TargetType,
+		Provider:           opts.Provider,
+		Filter:             opts.Filter,
+		Allow:              opts.Allow,
+		Protect:            opts.Protect,
+		Workers:            opts.Workers,
+		DeleteWorkers:      opts.DeleteWorkers,
+		Timeout:            opts.Timeout,
+		Retries:            opts.Retries,
+		ProbeRPS:           opts.ProbeRPS,
+		Output:             opts.Output,
+		History:            opts.HistoryPath,
+		Policy:             opts.Policy.String(),
+		Strategy:           opts.Strategy,
+		QuarantineFailures: opts.QuarantineFails,
+		QuarantineWindow:   opts.QuarantineWindow.String(),
+		BackupDir:          backupDir,
+		MaxDeleteRatio:     opts.MaxDeleteRatio,
+		MaxDelete:          opts.MaxDelete,
+		MinKeep:            opts.MinKeep,
+		Canaries:           opts.Canaries,
+		CanaryMode:         opts.CanaryMode,
+		Reverify:           opts.Reverify,
+		CoolingCycles:      opts.CoolingCycles,
+		Delete:             opts.Delete,
+		DeleteFromOutput:   opts.DeleteFromOutput,
+		DryRun:             opts.DryRun,
+		Cron:               opts.Cron,
+		CronTZ:             opts.CronTZ,
+		Listen:             opts.Listen,
+	}
+}
+
+func (r *Report) SetProbe(total, matched int, accounts []model.ProbeResult) {
+	if r == nil {
+		return
+	}
+	r.Counts.Total = total
+	r.Counts.Matched = matched
+	r.Accounts = append(r.Accounts[:0], accounts...)
+	c := model.SummarizeCapacity(accounts)
+	r.Capacity = &c
+}
+
+func (r *Report) AddDeletes(results []model.DeleteResult) {
+	if r == nil {
+		return
+	}
+	r.Deletes = append(r.Deletes, results...)
+}
+
+// Finish 记录结束时间与错误，并汇总计数
+func (r *Report) Finish(at time.Time, interrupted bool, err error) {
+	if r == nil {
+		return
+	}
+	r.FinishedAt = at
+	r.DurationMS = at.Sub(r.StartedAt).Milliseconds()
+	r.Interrupted = interrupted
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	c := &r.Counts
+	c.Probed = len(r.Accounts)
+	c.Invalid, c.Cooling = 0, 0
+	for _, v := range model.Verdicts {
+		c.Verdicts[v] = 0
+	}
+	for _, a := range r.Accounts {
+		c.Verdicts[a.Verdict]++
+		if a.Action != model.PolicyKeep {
+			c.Invalid++
+		}
+		if a.CoolingUntil != nil && a.Action == model.PolicyKeep {
+			c.Cooling++
+		}
+	}
+	c.DeleteAttempted, c.Deleted, c.DeleteFailed, c.Disabled, c.DisableFailed, c.Quarantined, c.Held, c.Skipped, c.Planned = 0, 0, 0, 0, 0, 0, 0, 0, 0
+	for _, d := range r.Deletes {
+		switch {
+		case d.DryRun:
+			c.Planned++
+		case d.Action == model.ActionHold:
+			c.Held++
+		case d.Action == model.ActionSkip:
+			c.Skipped++
+		case d.Action == model.ActionDisable:
+			if d.Error == "" {
+				c.Disabled++
+			} else {
+				c.DisableFailed++
+			}
+		case d.Action == model.ActionQuarantine:
+			c.Quarantined++
+		default:
+			c.DeleteAttempted++
+			if d.Deleted {
+				c.Deleted++
+			} else {
+				c.DeleteFailed++
+			}
+		}
+	}
+}
+
+func (r *Report) Write(path string) error {
+	if r == nil {
+		return nil
+	}
+	return output.WriteJSON(path, r)
+}
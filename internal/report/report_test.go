@@ -0,0 +1,62 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"clean_codex_token/internal/model"
+)
+
+func TestReportRedactsTokenAndCounts(t *testing.T) {
+	opts := &model.Options{Token: "secret-token", BaseURL: "http://x", Policy: model.DefaultPolicy(), QuarantineWindow: time.Hour}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := New(opts, "check_delete", t0)
+	r.SetProbe(5, 3, []model.ProbeResult{
+		{Name: "a", Verdict: model.VerdictUnauthorized, Action: model.PolicyDelete, LatencyMS: 20},
+		{Name: "b", Verdict: model.VerdictHealthy, Action: model.PolicyKeep, LatencyMS: 10},
+		{Name: "c", Verdict: model.VerdictForbidden, Action: model.PolicyQuarantine},
+	})
+	r.AddDeletes([]model.DeleteResult{
+		{Name: "a", Deleted: true, Action: model.ActionDelete, StatusCode: 200},
+		{Name: "c", Action: model.ActionHold},
+	})
+	r.Finish(t0.Add(1500*time.Millisecond), false, errors.New("boom"))
+
+	p := filepath.Join(t.TempDir(), "report.json")
+	if err := r.Write(p); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(p)
+	if strings.Contains(string(b), "secret-token") {
+		t.Fatalf("token leaked into report: %s", b)
+	}
+	var got Report
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != SchemaVersion || got.DurationMS != 1500 || got.Error != "boom" || got.Options.Token != redacted {
+		t.Fatalf("unexpected header: %+v", got)
+	}
+	c := got.Counts
+	if c.Total != 5 || c.Matched != 3 || c.Probed != 3 || c.Invalid != 2 || c.Deleted != 1 || c.DeleteAttempted != 1 || c.Held != 1 {
+		t.Fatalf("unexpected counts: %+v", c)
+	}
+	if c.Verdicts[model.VerdictHealthy] != 1 || c.Verdicts[model.VerdictUnknown] != 0 || len(c.Verdicts) != len(model.Verdicts) {
+		t.Fatalf("unexpected verdict counts: %v", c.Verdicts)
+	}
+}
+
+func TestNilReportIsNoop(t *testing.T) {
+	var r *Report
+	r.SetProbe(1, 1, nil)
+	r.AddDeletes(nil)
+	r.Finish(time.Now(), false, nil)
+	if err := r.Write(filepath.Join(t.TempDir(), "x.json")); err != nil {
+		t.Fatal(err)
+	}
+}
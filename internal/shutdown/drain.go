@@ -0,0 +1,49 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInterrupted 收到退出信号，任务未全部完成
+var ErrInterrupted = errors.New("收到退出信号，任务已中断")
+
+type stopKey struct{}
+
+// WithDrain 返回用于发起请求的工作 context：stop 结束后不再派发新任务，
+// 但已发出的请求仍可继续 timeout 时长，超时后工作 context 才被取消。
+func WithDrain(stop context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancel(context.WithValue(context.WithoutCancel(stop), stopKey{}, stop.Done()))
+	go func() {
+		select {
+		case <-stop.Done():
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			select {
+			case <-t.C:
+				cancel()
+			case <-work.Done():
+			}
+		case <-work.Done():
+		}
+	}()
+	return work, cancel
+}
+
+// Stopping 返回停止派发新任务的信号；未经 WithDrain 包装时等同 ctx.Done()
+func Stopping(ctx context.Context) <-chan struct{} {
+	if ch, ok := ctx.Value(stopKey{}).(<-chan struct{}); ok {
+		return ch
+	}
+	return ctx.Done()
+}
+
+func Interrupted(ctx context.Context) bool {
+	select {
+	case <-Stopping(ctx):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,30 @@
+package shutdown
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithDrain(t *testing.T) {
+	stop, cancelStop := context.WithCancel(context.Background())
+	work, cancel := WithDrain(stop, 50*time.Millisecond)
+	defer cancel()
+
+	if Interrupted(work) {
+		t.Fatalf("should not be interrupted yet")
+	}
+	cancelStop()
+	<-Stopping(work)
+	if work.Err() != nil {
+		t.Fatalf("work context must survive during drain")
+	}
+	select {
+	case <-work.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("work context should be cancelled after drain timeout")
+	}
+	if !Interrupted(work) {
+		t.Fatalf("expected interrupted")
+	}
+}
@@ -2,7 +2,9 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -11,8 +13,10 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"clean_codex_token/internal/app"
+	"clean_codex_token/internal/model"
 )
 
 func TestAppFlowInteractiveCheck(t *testing.T) {
@@ -38,14 +42,7 @@ func TestAppFlowInteractiveCheck(t *testing.T) {
 		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
 	}
 
-	b, err := os.ReadFile(outFile)
-	if err != nil {
-		t.Fatal(err)
-	}
-	var rows []map[string]any
-	if err := json.Unmarshal(b, &rows); err != nil {
-		t.Fatal(err)
-	}
+	rows := readOutputRows(t, outFile)
 	if len(rows) != 2 {
 		t.Fatalf("expected 2 invalid rows, got %d", len(rows))
 	}
@@ -117,80 +114,1719 @@ func TestAppFlowDeleteFromOutput(t *testing.T) {
 	}
 }
 
+func TestAppFlowDryRun(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	planFile := filepath.Join(dir, "plan.json")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--plan-output", planFile,
+		"--delete",
+		"--dry-run",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitDryRun {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("dry-run must not delete, got %+v", d)
+	}
+
+	b, err := os.ReadFile(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan []map[string]any
+	if err := json.Unmarshal(b, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0]["name"] != "a-401" || plan[1]["verdict"] != "unauthorized" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if !strings.Contains(stdout.String(), "[DRY-RUN] 将删除 c-401") {
+		t.Fatalf("missing dry-run line: %s", stdout.String())
+	}
+}
+
+func TestAppFlowBackupAndRestore(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--backup-dir", backupDir,
+		"--delete",
+		"--yes",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+
+	archives, err := os.ReadDir(backupDir)
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("expected one archive, got %v err=%v", archives, err)
+	}
+	archive := filepath.Join(backupDir, archives[0].Name())
+	b, err := os.ReadFile(filepath.Join(archive, "files", "a-401"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"auth_index":"idx-a"`) {
+		t.Fatalf("unexpected backup content: %s", b)
+	}
+
+	code = app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--restore", archive,
+		"--restore-names", "c-401",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != 0 {
+		t.Fatalf("restore exit code=%d stderr=%s", code, stderr.String())
+	}
+	up := srv.uploadNames()
+	if len(up) != 1 || up[0] != "c-401" {
+		t.Fatalf("unexpected uploads: %+v", up)
+	}
+
+	// 恢复到其他管理服务：默认拒绝，--force 时照常恢复
+	other := newMockServer(t)
+	defer other.Close()
+	restore := []string{"restore", archive, "--token", "t", "--base-url", other.URL(), "--names", "c-401"}
+	stderr.Reset()
+	if code := app.Run(restore, strings.NewReader(""), stdout, stderr); code != app.ExitError || !strings.Contains(stderr.String(), "其他管理服务") {
+		t.Fatalf("restore to another server should be refused, code=%d stderr=%s", code, stderr.String())
+	}
+	if up := other.uploadNames(); len(up) != 0 {
+		t.Fatalf("refused restore must not upload, got %v", up)
+	}
+	if code := app.Run(append(restore, "--force"), strings.NewReader(""), stdout, stderr); code != app.ExitOK || len(other.uploadNames()) != 1 {
+		t.Fatalf("--force restore: code=%d uploads=%v stderr=%s", code, other.uploadNames(), stderr.String())
+	}
+}
+
+func TestAppFlowBackupDirUnavailableFailsDeletion(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// 备份根目录被普通文件占用，无法创建
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.WriteFile(backupDir, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reportFile := filepath.Join(dir, "report.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--backup-dir", backupDir,
+		"--report", reportFile,
+		"--delete",
+		"--yes",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "备份目录不可用") {
+		t.Fatalf("expected aborted deletion to fail the run, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("nothing should be deleted without a backup, got %v", got)
+	}
+	var rep struct {
+		Error  string `json:"error"`
+		Counts struct {
+			Deleted      int `json:"deleted"`
+			DeleteFailed int `json:"delete_failed"`
+		} `json:"counts"`
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Error == "" || rep.Counts.Deleted != 0 || rep.Counts.DeleteFailed != 2 {
+		t.Fatalf("report should show the refused deletion: %+v", rep)
+	}
+}
+
+func TestAppFlowDisableStrategy(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	args := []string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--strategy", "disable",
+		"--quarantine-failures", "2",
+		"--quarantine-window", "0s",
+		"--delete",
+		"--yes",
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("first run must only disable, deleted %+v", d)
+	}
+	if st := srv.statusCalls(); len(st) != 2 || st[0] != "a-401=true" || st[1] != "c-401=true" {
+		t.Fatalf("unexpected status calls: %+v", st)
+	}
+
+	// a 恢复正常，c 仍然 401：隔离前的失败不计入，隔离后只失败了 1 次
+	srv.setStatus("idx-a", 200)
+	if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("failures before quarantine must not confirm deletion, got %+v", d)
+	}
+	if st := srv.statusCalls(); len(st) != 3 || st[2] != "a-401=false" {
+		t.Fatalf("expected a-401 to be re-enabled: %+v", st)
+	}
+
+	// 隔离后限流不计入确认次数
+	srv.setStatus("idx-c", 429)
+	if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	srv.setStatus("idx-c", 401)
+	if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("rate-limited result must break the failure streak, got %+v", d)
+	}
+
+	if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 1 || d[0] != "c-401" {
+		t.Fatalf("expected c-401 to be deleted after 2 failures since quarantine, got %+v", d)
+	}
+}
+
+func TestAppFlowVerdictDedupAndPolicy(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	srv.setBody("idx-a", `{"usage":{"limit":0}}`)
+	srv.setBody("idx-b", `{"usage":{"limit":0}}`)
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	run := func(extra ...string) []map[string]any {
+		t.Helper()
+		// 不冷却，额度耗尽直接按 policy 处置
+		args := append([]string{"--token", "t", "--base-url", srv.URL(), "--output", outFile, "--delete", "--dry-run", "--cooling-cycles", "0"}, extra...)
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != app.ExitDryRun {
+			t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+		}
+		rows := readOutputRows(t, outFile)
+		return rows
+	}
+
+	rows := run()
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 de-duplicated rows, got %+v", rows)
+	}
+	if rows[0]["verdict"] != "unauthorized" || rows[1]["verdict"] != "quota-exhausted" {
+		t.Fatalf("unexpected verdicts: %+v", rows)
+	}
+
+	rows = run("--policy", "unauthorized=keep")
+	if len(rows) != 1 || rows[0]["name"] != "b-200" {
+		t.Fatalf("policy should keep 401 accounts, got %+v", rows)
+	}
+}
+
+func TestAppFlowInterruptedFlushesPartialResults(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	srv.setDelay(200 * time.Millisecond)
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	stop, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.RunContext(stop, []string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--workers", "1",
+		"--delete",
+		"--yes",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitInterrupted {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("interrupted run must not delete, got %+v", d)
+	}
+
+	rows := readOutputRows(t, outFile)
+	// 在途的 a-401 探测被允许完成，之后不再派发
+	if len(rows) != 1 || rows[0]["name"] != "a-401" {
+		t.Fatalf("unexpected partial rows: %+v", rows)
+	}
+}
+
 type mockServer struct {
 	ts          *httptest.Server
 	mu          sync.Mutex
 	deleted     []string
+	uploaded    []string
+	status      []string
 	authIndexes map[string]int
+	bodies      map[string]string
+	delay       time.Duration
+	probed      []string
+	files       []map[string]any
+	// afterProbe 每次探测响应后调用，用于模拟运行中上游状态变化
+	afterProbe func(authIndex string)
 }
 
-func newMockServer(t *testing.T) *mockServer {
-	t.Helper()
-	m := &mockServer{
-		authIndexes: map[string]int{
-			"idx-a": 401,
-			"idx-b": 200,
-			"idx-c": 401,
-		},
+func TestAppFlowJSONLogs(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--delete",
+		"--yes",
+		"--no-backup",
+		"--log-format", "json",
+		"--log-level", "debug",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
 	}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/v0/management/auth-files", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			_ = json.NewEncoder(w).Encode(map[string]any{"files": []map[string]any{
-				{"name": "a-401", "account": "a@test", "auth_index": "idx-a", "type": "codex", "provider": "openai"},
-				{"name": "b-200", "account": "b@test", "auth_index": "idx-b", "typo": "codex", "provider": "openai"},
-				{"name": "c-401", "account": "c@test", "auth_index": "idx-c", "type": "codex", "provider": "openai"},
-			}})
-			return
-		}
-		if r.Method == http.MethodDelete {
-			name := r.URL.Query().Get("name")
-			m.mu.Lock()
-			m.deleted = append(m.deleted, name)
-			m.mu.Unlock()
-			_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
-			return
+
+	probed := map[string]string{}
+	deleted := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("non-JSON log line %q: %v", line, err)
 		}
-		w.WriteHeader(http.StatusMethodNotAllowed)
-	})
-	mux.HandleFunc("/v0/management/api-call", func(w http.ResponseWriter, r *http.Request) {
-		var payload map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+		name, _ := rec["name"].(string)
+		if rec["level"] == "DEBUG" && rec["verdict"] != nil && rec["latency_ms"] != nil {
+			probed[name], _ = rec["verdict"].(string)
 		}
-		authIndex, _ := payload["authIndex"].(string)
-		sc, ok := m.authIndexes[authIndex]
-		if !ok {
-			sc = 200
+		if rec["deleted"] == true {
+			deleted[name] = true
 		}
-		_ = json.NewEncoder(w).Encode(map[string]any{"status_code": sc})
-	})
-	m.ts = httptest.NewServer(mux)
-	return m
+	}
+	if probed["a-401"] != "unauthorized" || probed["b-200"] != "healthy" || probed["c-401"] != "unauthorized" {
+		t.Fatalf("unexpected per-account probe events: %v", probed)
+	}
+	if !deleted["a-401"] || !deleted["c-401"] || len(deleted) != 2 {
+		t.Fatalf("unexpected per-account delete events: %v", deleted)
+	}
 }
 
-func (m *mockServer) URL() string { return m.ts.URL }
-func (m *mockServer) Close()      { m.ts.Close() }
-
-func (m *mockServer) deleteNames() []string {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	out := make([]string, len(m.deleted))
-	copy(out, m.deleted)
-	return out
-}
+func TestAppFlowReport(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
 
-func TestAppFlowCronNoToken(t *testing.T) {
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
 	code := app.Run([]string{
-		"--cron", "*/10 * * * *",
+		"--token", "secret-token",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--report", reportFile,
+		"--delete",
+		"--yes",
+		"--no-backup",
 	}, strings.NewReader(""), stdout, stderr)
-	if code == 0 {
-		t.Fatalf("expected non-zero exit code")
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
 	}
-	if !strings.Contains(stderr.String(), "cron 无人值守模式下缺少管理 token") {
-		t.Fatalf("unexpected stderr: %s", stderr.String())
+
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret-token") {
+		t.Fatal("token leaked into report")
+	}
+	var rep struct {
+		SchemaVersion int    `json:"schema_version"`
+		Mode          string `json:"mode"`
+		Accounts      []struct {
+			Name    string `json:"name"`
+			Verdict string `json:"verdict"`
+		} `json:"accounts"`
+		Deletes []struct {
+			Name       string `json:"name"`
+			Deleted    bool   `json:"deleted"`
+			StatusCode int    `json:"status_code"`
+		} `json:"deletes"`
+		Counts struct {
+			Matched int `json:"matched"`
+			Deleted int `json:"deleted"`
+		} `json:"counts"`
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.SchemaVersion != 1 || rep.Mode != "check_delete" || rep.Counts.Matched != 3 || rep.Counts.Deleted != 2 {
+		t.Fatalf("unexpected report: %s", b)
+	}
+	if len(rep.Accounts) != 3 || rep.Accounts[1].Name != "b-200" || rep.Accounts[1].Verdict != "healthy" {
+		t.Fatalf("report should include healthy accounts: %+v", rep.Accounts)
+	}
+	if len(rep.Deletes) != 2 || !rep.Deletes[0].Deleted || rep.Deletes[0].StatusCode != 200 {
+		t.Fatalf("unexpected delete attempts: %+v", rep.Deletes)
+	}
+}
+
+func TestAppFlowCSVOutputThenDelete(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.csv")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	base := []string{"--token", "t", "--base-url", srv.URL(), "--output", outFile, "--no-backup"}
+	if code := app.Run(append(base, "--output-format", "csv"), strings.NewReader("1\n\n\n\n\n"), stdout, stderr); code != 0 {
+		t.Fatalf("check exit code=%d stderr=%s", code, stderr.String())
+	}
+	b, _ := os.ReadFile(outFile)
+	if !strings.HasPrefix(string(b), "# meta: ") || !strings.Contains(string(b), "\nname,account,auth_index") {
+		t.Fatalf("unexpected csv output: %s", b)
+	}
+
+	// --delete-from-output 不需要指定格式
+	if code := app.Run(append(base, "--delete-from-output", "--yes"), strings.NewReader(""), stdout, stderr); code != 0 {
+		t.Fatalf("delete exit code=%d stderr=%s", code, stderr.String())
+	}
+	d := srv.deleteNames()
+	sort.Strings(d)
+	if len(d) != 2 || d[0] != "a-401" || d[1] != "c-401" {
+		t.Fatalf("unexpected deleted names: %+v", d)
+	}
+}
+
+func TestAppFlowNotifyWebhook(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	var (
+		mu   sync.Mutex
+		got  map[string]any
+		hits int
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits++
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer hook.Close()
+
+	dir := t.TempDir()
+	confFile := filepath.Join(dir, "config.json")
+	conf := map[string]any{"notify": map[string]any{"min_deleted": 1, "targets": []any{map[string]any{"type": "webhook", "url": hook.URL}}}}
+	b, _ := json.Marshal(conf)
+	if err := os.WriteFile(confFile, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--config", confFile,
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 1 {
+		t.Fatalf("expected one notification, got %d", hits)
+	}
+	text, _ := got["text"].(string)
+	if !strings.Contains(text, "a-401") || !strings.Contains(text, "c-401") {
+		t.Fatalf("notification should list deleted names: %q", text)
+	}
+}
+
+// readOutputRows 读取 json 格式 output 中的账号
+func readOutputRows(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Meta     map[string]any   `json:"meta"`
+		Accounts []map[string]any `json:"accounts"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Meta["generated_at"] == nil || doc.Meta["base_url"] == nil {
+		t.Fatalf("output should embed generation metadata: %s", b)
+	}
+	return doc.Accounts
+}
+
+func newMockServer(t *testing.T) *mockServer {
+	t.Helper()
+	m := &mockServer{
+		authIndexes: map[string]int{
+			"idx-a": 401,
+			"idx-b": 200,
+			"idx-c": 401,
+		},
+		bodies: map[string]string{},
+	}
+	m.files = []map[string]any{
+		{"name": "a-401", "account": "a@test", "auth_index": "idx-a", "type": "codex", "provider": "openai"},
+		{"name": "b-200", "account": "b@test", "auth_index": "idx-b", "typo": "codex", "provider": "openai"},
+		{"name": "c-401", "account": "c@test", "auth_index": "idx-c", "type": "codex", "provider": "openai"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v0/management/auth-files/download", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		for _, f := range m.authFiles() {
+			if f["name"] == name {
+				_ = json.NewEncoder(w).Encode(f)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/v0/management/auth-files/status", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name     string `json:"name"`
+			Disabled bool   `json:"disabled"`
+		}
+		if r.Method != http.MethodPatch || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		m.status = append(m.status, fmt.Sprintf("%s=%t", body.Name, body.Disabled))
+		m.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+	})
+	mux.HandleFunc("/v0/management/auth-files", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(map[string]any{"files": m.authFiles()})
+			return
+		}
+		if r.Method == http.MethodPost {
+			m.mu.Lock()
+			m.uploaded = append(m.uploaded, r.URL.Query().Get("name"))
+			m.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			name := r.URL.Query().Get("name")
+			m.mu.Lock()
+			m.deleted = append(m.deleted, name)
+			m.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	mux.HandleFunc("/v0/management/api-call", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		authIndex, _ := payload["authIndex"].(string)
+		m.mu.Lock()
+		sc, ok := m.authIndexes[authIndex]
+		body := m.bodies[authIndex]
+		delay := m.delay
+		m.probed = append(m.probed, authIndex)
+		m.mu.Unlock()
+		time.Sleep(delay)
+		if !ok {
+			sc = 200
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status_code": sc, "body": body})
+		if m.afterProbe != nil {
+			m.afterProbe(authIndex)
+		}
+	})
+	m.ts = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockServer) URL() string { return m.ts.URL }
+func (m *mockServer) Close()      { m.ts.Close() }
+
+func (m *mockServer) authFiles() []map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]any(nil), m.files...)
+}
+
+func (m *mockServer) addFile(f map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files = append(m.files, f)
+}
+
+func (m *mockServer) probedIndexes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, len(m.probed))
+	copy(out, m.probed)
+	return out
+}
+
+func (m *mockServer) deleteNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, len(m.deleted))
+	copy(out, m.deleted)
+	return out
+}
+
+func (m *mockServer) statusCalls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, len(m.status))
+	copy(out, m.status)
+	return out
+}
+
+func (m *mockServer) setStatus(authIndex string, sc int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authIndexes[authIndex] = sc
+}
+
+func (m *mockServer) setDelay(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delay = d
+}
+
+func (m *mockServer) setBody(authIndex, body string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bodies[authIndex] = body
+}
+
+func (m *mockServer) uploadNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, len(m.uploaded))
+	copy(out, m.uploaded)
+	return out
+}
+
+func TestAppFlowCronNoToken(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--cron", "*/10 * * * *",
+	}, strings.NewReader(""), stdout, stderr)
+	if code == 0 {
+		t.Fatalf("expected non-zero exit code")
+	}
+	if !strings.Contains(stderr.String(), "cron 无人值守模式下缺少管理 token") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestAppFlowMultiTarget(t *testing.T) {
+	prod := newMockServer(t)
+	defer prod.Close()
+	staging := newMockServer(t)
+	defer staging.Close()
+	staging.setStatus("idx-a", 200)
+
+	dir := t.TempDir()
+	confFile := filepath.Join(dir, "config.json")
+	conf := map[string]any{"targets": []any{
+		map[string]any{"name": "prod", "base_url": prod.URL(), "token": "t1"},
+		map[string]any{"name": "staging", "base_url": staging.URL(), "token": "t2"},
+	}}
+	b, _ := json.Marshal(conf)
+	if err := os.WriteFile(confFile, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--config", confFile,
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+
+	if got := prod.deleteNames(); len(got) != 2 {
+		t.Fatalf("prod deletes=%v", got)
+	}
+	if got := staging.deleteNames(); len(got) != 1 || got[0] != "c-401" {
+		t.Fatalf("staging deletes=%v", got)
+	}
+	for _, name := range []string{"invalid.prod.json", "invalid.staging.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected per-target output %s: %v", name, err)
+		}
+	}
+	if !strings.Contains(stdout.String(), "[prod]") || !strings.Contains(stdout.String(), "多目标执行完成") {
+		t.Fatalf("expected per-target summary, got:\n%s", stdout.String())
+	}
+}
+
+func TestAppFlowMultiTargetNeedsYes(t *testing.T) {
+	dir := t.TempDir()
+	confFile := filepath.Join(dir, "config.json")
+	conf := map[string]any{"targets": []any{
+		map[string]any{"name": "a", "base_url": "http://127.0.0.1:1", "token": "t"},
+		map[string]any{"name": "b", "base_url": "http://127.0.0.1:2", "token": "t"},
+	}}
+	b, _ := json.Marshal(conf)
+	if err := os.WriteFile(confFile, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"--config", confFile, "--delete"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "--yes") {
+		t.Fatalf("expected --yes error, code=%d stderr=%s", code, stderr.String())
+	}
+}
+
+func TestAppFlowGuardRefusesMassDeletion(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--report", reportFile,
+		"--max-delete-ratio", "0.5",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitError {
+		t.Fatalf("expected exit code %d, got %d stderr=%s", app.ExitError, code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("guard should refuse all deletions, got %v", got)
+	}
+	if !strings.Contains(stderr.String(), "已拒绝删除") {
+		t.Fatalf("expected refusal reason, got stderr=%s", stderr.String())
+	}
+
+	var rep struct {
+		Refused string `json:"refused"`
+		Error   string `json:"error"`
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Refused != "max_delete_ratio" || rep.Error == "" {
+		t.Fatalf("report should record refusal: %+v", rep)
+	}
+}
+
+func TestAppFlowGuardDeleteFromOutput(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(outFile, []byte(`[{"name":"a-401"},{"name":"c-401"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(extra ...string) (int, string) {
+		stderr := &bytes.Buffer{}
+		args := append([]string{"--token", "t", "--base-url", srv.URL(), "--output", outFile, "--delete-from-output", "--yes", "--no-backup"}, extra...)
+		code := app.Run(args, strings.NewReader(""), &bytes.Buffer{}, stderr)
+		return code, stderr.String()
+	}
+
+	// 没有探测历史时不知道剩余账号是否健康，--min-keep 拒绝
+	if code, stderr := run("--min-keep", "1"); code != app.ExitError || !strings.Contains(stderr, "仅剩 0 个健康账号") {
+		t.Fatalf("expected refusal without history, got code=%d stderr=%s", code, stderr)
+	}
+	if code := app.Run([]string{"check", "--token", "t", "--base-url", srv.URL(), "--output", outFile}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); code != app.ExitOK {
+		t.Fatalf("check exit code=%d", code)
+	}
+
+	// 管理服务中共 3 个账号，删除 2 个：比例 66.7% 超过 50%、剩余健康账号 1 个低于 2
+	for _, extra := range [][]string{{"--max-delete-ratio", "0.5"}, {"--min-keep", "2"}} {
+		code, stderr := run(extra...)
+		if code != app.ExitError || !strings.Contains(stderr, "已拒绝删除") {
+			t.Fatalf("%v: expected refusal, got code=%d stderr=%s", extra, code, stderr)
+		}
+		if got := srv.deleteNames(); len(got) != 0 {
+			t.Fatalf("%v: guard should refuse all deletions, got %v", extra, got)
+		}
+	}
+
+	if code, stderr := run("--max-delete-ratio", "0.7", "--min-keep", "1"); code != app.ExitOK {
+		t.Fatalf("guard within limits should allow, got code=%d stderr=%s", code, stderr)
+	}
+	if got := srv.deleteNames(); len(got) != 2 {
+		t.Fatalf("expected 2 deletions, got %v", got)
+	}
+}
+
+func TestAppFlowGuardMinKeepCountsHealthyOnly(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	// 两个限流账号不处置，但也不可用，不能算作剩余账号
+	for _, idx := range []string{"idx-d", "idx-e"} {
+		srv.addFile(map[string]any{"name": idx + "-429", "account": idx + "@test", "auth_index": idx, "type": "codex", "provider": "openai"})
+		srv.setStatus(idx, 429)
+	}
+
+	dir := t.TempDir()
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--min-keep", "2",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "仅剩 1 个健康账号") {
+		t.Fatalf("expected min-keep refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("guard should refuse all deletions, got %v", got)
+	}
+}
+
+func TestAppFlowGuardCanary(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--canaries", "c@test",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "c-401") {
+		t.Fatalf("expected canary refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("canary failure should refuse deletions, got %v", got)
+	}
+
+	// 金丝雀正常时照常删除
+	code = app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--canaries", "b-200",
+		"--max-delete", "2",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if code != 0 || len(srv.deleteNames()) != 2 {
+		t.Fatalf("expected deletions to proceed, code=%d deletes=%v", code, srv.deleteNames())
+	}
+}
+
+func TestAppFlowCanarySharedAccountStillProbed(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	// 与金丝雀同一 account 的另一个文件已失效
+	srv.addFile(map[string]any{"name": "b-dup", "account": "b@test", "auth_index": "idx-d", "type": "codex", "provider": "openai"})
+	srv.setStatus("idx-d", 401)
+
+	dir := t.TempDir()
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--canaries", "b@test",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	probed := srv.probedIndexes()
+	sort.Strings(probed)
+	if strings.Join(probed, ",") != "idx-a,idx-b,idx-b,idx-c,idx-d" {
+		t.Fatalf("only the chosen canary file should be skipped in dispatch, probed %v", probed)
+	}
+	d := srv.deleteNames()
+	sort.Strings(d)
+	if strings.Join(d, ",") != "a-401,b-dup,c-401" {
+		t.Fatalf("file sharing the canary account should be cleaned, got %v", d)
+	}
+}
+
+func TestAppFlowCanaryAbort(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--canaries", "idx-a",
+		"--canary-mode", "abort",
+	}, strings.NewReader("1\n"), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "金丝雀") {
+		t.Fatalf("expected canary abort, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.probedIndexes(); len(got) != 1 || got[0] != "idx-a" {
+		t.Fatalf("only the canary should be probed, got %v", got)
+	}
+	if _, err := os.Stat(outFile); !os.IsNotExist(err) {
+		t.Fatalf("abort should not write output, stat err=%v", err)
+	}
+}
+
+func TestAppFlowCanaryReportOnly(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	historyFile := filepath.Join(dir, "history.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--history", historyFile,
+		"--canaries", "a@test",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError {
+		t.Fatalf("expected refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("report-only run should not delete, got %v", got)
+	}
+	if got := srv.probedIndexes(); len(got) != 3 {
+		t.Fatalf("report-only run should still probe every account once, got %v", got)
+	}
+	if rows := readOutputRows(t, outFile); len(rows) != 2 {
+		t.Fatalf("expected exported results, rows=%v", rows)
+	}
+	if recs := readHistoryRecords(t, historyFile); len(recs) != 0 {
+		t.Fatalf("untrusted verdicts must not be recorded, got %v", recs)
+	}
+
+	// 金丝雀通过时结论照常写入历史
+	code = app.Run([]string{"check", "--token", "t", "--base-url", srv.URL(), "--output", outFile, "--history", historyFile, "--canaries", "b-200"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if recs := readHistoryRecords(t, historyFile); len(recs) != 3 || recs[0]["consecutive_failures"] != float64(1) {
+		t.Fatalf("trusted verdicts should be recorded, got %v", recs)
+	}
+}
+
+// readHistoryRecords 读取探测历史文件中的记录（按 key 排序）
+func readHistoryRecords(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		Records []map[string]any `json:"records"`
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data.Records
+}
+
+func TestAppFlowCanaryDegradesMidRun(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	// 金丝雀首次探测后上游开始返回 401
+	srv.afterProbe = func(authIndex string) {
+		if authIndex == "idx-b" {
+			srv.setStatus("idx-b", 401)
+		}
+	}
+
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "history.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--history", historyFile,
+		"--canaries", "b-200",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "复查") {
+		t.Fatalf("expected post-run canary refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("degraded run should not delete, got %v", got)
+	}
+	probed := srv.probedIndexes()
+	if len(probed) != 4 || probed[0] != "idx-b" || probed[3] != "idx-b" {
+		t.Fatalf("canary should be probed first and last, got %v", probed)
+	}
+	if recs := readHistoryRecords(t, historyFile); len(recs) != 0 {
+		t.Fatalf("verdicts of a run failing the post-run canary check must not be recorded, got %v", recs)
+	}
+}
+
+func TestAppFlowReverifySkipsRecovered(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	// a-401 在首次探测后恢复正常
+	srv.afterProbe = func(authIndex string) {
+		if authIndex == "idx-a" {
+			srv.setStatus("idx-a", 200)
+		}
+	}
+
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--report", reportFile,
+		"--reverify",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.deleteNames(); len(got) != 1 || got[0] != "c-401" {
+		t.Fatalf("recovered account should be skipped, deleted=%v", got)
+	}
+
+	var rep struct {
+		Counts struct {
+			Deleted      int `json:"deleted"`
+			DeleteFailed int `json:"delete_failed"`
+			Skipped      int `json:"skipped"`
+		} `json:"counts"`
+		Deletes []model.DeleteResult `json:"deletes"`
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Counts.Deleted != 1 || rep.Counts.Skipped != 1 || rep.Counts.DeleteFailed != 0 {
+		t.Fatalf("unexpected counts: %+v", rep.Counts)
+	}
+	for _, d := range rep.Deletes {
+		if d.Name == "a-401" && (d.Action != model.ActionSkip || d.Reason != "skipped: recovered" || d.Deleted) {
+			t.Fatalf("unexpected skip record: %+v", d)
+		}
+	}
+}
+
+func TestAppFlowReverifyDeleteFromOutput(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	// 旧 output 中的 b-200 已恢复，gone 已不在管理服务中
+	content := `[{"name":"a-401"},{"name":"b-200"},{"name":"gone"}]`
+	if err := os.WriteFile(outFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--delete-from-output",
+		"--reverify",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if code != 0 {
+		t.Fatalf("exit code=%d", code)
+	}
+	if got := srv.deleteNames(); len(got) != 1 || got[0] != "a-401" {
+		t.Fatalf("only still-invalid accounts should be deleted, got %v", got)
+	}
+	if !strings.Contains(stdout.String(), "复查恢复跳过=1") {
+		t.Fatalf("expected skip summary, got:\n%s", stdout.String())
+	}
+}
+
+func TestAppFlowDeleteFromOutputRejectsOtherServer(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	other := newMockServer(t)
+	defer other.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	if code := app.Run([]string{"--token", "t", "--base-url", srv.URL(), "--output", outFile}, strings.NewReader("1\n\n\n\n\n"), &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("check exit code=%d", code)
+	}
+
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"--token", "t", "--base-url", other.URL(), "--output", outFile, "--delete-from-output", "--yes", "--no-backup"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "其他管理服务") {
+		t.Fatalf("expected server mismatch refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if len(srv.deleteNames()) != 0 || len(other.deleteNames()) != 0 {
+		t.Fatal("mismatched output must not delete anything")
+	}
+}
+
+func TestAppFlowDeleteFromOutputMaxAge(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	doc := map[string]any{
+		"meta":     map[string]any{"tool": "clean-codex-accounts", "generated_at": time.Now().Add(-48 * time.Hour), "base_url": srv.URL(), "target_type": "codex"},
+		"accounts": []any{map[string]any{"name": "a-401"}, map[string]any{"name": "gone"}},
+	}
+	b, _ := json.Marshal(doc)
+	if err := os.WriteFile(outFile, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--token", "t", "--base-url", srv.URL(), "--output", outFile, "--delete-from-output", "--yes", "--no-backup"}
+
+	stderr := &bytes.Buffer{}
+	if code := app.Run(args, strings.NewReader(""), &bytes.Buffer{}, stderr); code != app.ExitError || !strings.Contains(stderr.String(), "--max-output-age") {
+		t.Fatalf("expected stale output refusal, code=%d stderr=%s", code, stderr.String())
+	}
+	if len(srv.deleteNames()) != 0 {
+		t.Fatal("stale output must not delete anything")
+	}
+
+	stdout := &bytes.Buffer{}
+	if code := app.Run(append(args, "--max-output-age", "72h"), strings.NewReader(""), stdout, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("exit code=%d", code)
+	}
+	if got := srv.deleteNames(); len(got) != 1 || got[0] != "a-401" {
+		t.Fatalf("expected only existing accounts deleted, got %v", got)
+	}
+	if !strings.Contains(stdout.String(), "已不在管理服务中") || !strings.Contains(stdout.String(), "gone") {
+		t.Fatalf("expected missing-name warning, got:\n%s", stdout.String())
+	}
+}
+
+func TestAppFlowFilterAndProtect(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", filepath.Join(dir, "invalid.json"),
+		"--report", reportFile,
+		"--filter", `account ~ "*@test" && account != "b@test"`,
+		"--protect", "c-*",
+		"--delete",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if got := srv.probedIndexes(); len(got) != 2 {
+		t.Fatalf("filter should exclude b-200 from probing, got %v", got)
+	}
+	if got := srv.deleteNames(); len(got) != 1 || got[0] != "a-401" {
+		t.Fatalf("protected account must not be deleted, got %v", got)
+	}
+
+	var rep struct {
+		Counts struct {
+			Matched int `json:"matched"`
+			Skipped int `json:"skipped"`
+		} `json:"counts"`
+		Deletes []model.DeleteResult `json:"deletes"`
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Counts.Matched != 2 || rep.Counts.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", rep.Counts)
+	}
+	for _, d := range rep.Deletes {
+		if d.Name == "c-401" && d.Reason != "skipped: protected" {
+			t.Fatalf("unexpected protected record: %+v", d)
+		}
+	}
+}
+
+func TestAppFlowAllowListFromOutput(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(outFile, []byte(`[{"name":"a-401"},{"name":"c-401"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code := app.Run([]string{
+		"--token", "t",
+		"--base-url", srv.URL(),
+		"--output", outFile,
+		"--allow", "a-*",
+		"--delete-from-output",
+		"--yes",
+		"--no-backup",
+	}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if code != 0 {
+		t.Fatalf("exit code=%d", code)
+	}
+	if got := srv.deleteNames(); len(got) != 1 || got[0] != "a-401" {
+		t.Fatalf("only allowed accounts should be deleted, got %v", got)
+	}
+}
+
+func TestAppFlowInvalidFilter(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"--token", "t", "--base-url", "http://127.0.0.1:1", "--filter", `email ~`, "--delete", "--yes"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "--filter") {
+		t.Fatalf("expected filter syntax error, code=%d stderr=%s", code, stderr.String())
+	}
+}
+
+func TestAppFlowSubcommandCheckAndDelete(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	stderr := &bytes.Buffer{}
+	// check 不进入交互菜单，也不处置
+	code := app.Run([]string{"check", "--token", "t", "--base-url", srv.URL(), "--output", outFile}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if rows := readOutputRows(t, outFile); len(rows) != 2 {
+		t.Fatalf("expected 2 invalid rows, got %d", len(rows))
+	}
+	if got := srv.deleteNames(); len(got) != 0 {
+		t.Fatalf("check must not delete, got %v", got)
+	}
+
+	code = app.Run([]string{"delete", "--from-output", "--token", "t", "--base-url", srv.URL(), "--output", outFile, "--yes", "--no-backup"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	d := srv.deleteNames()
+	sort.Strings(d)
+	if len(d) != 2 || d[0] != "a-401" || d[1] != "c-401" {
+		t.Fatalf("unexpected deleted names: %+v", d)
+	}
+}
+
+func TestAppFlowSubcommandListAndInspect(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"list", "--token", "t", "--base-url", srv.URL(), "--filter", `account != "c@test"`}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if s := stdout.String(); !strings.Contains(s, "a-401") || !strings.Contains(s, "b-200") || strings.Contains(s, "c-401") {
+		t.Fatalf("unexpected list output:\n%s", s)
+	}
+	if got := srv.probedIndexes(); len(got) != 0 {
+		t.Fatalf("list must not probe, got %v", got)
+	}
+
+	stdout.Reset()
+	code = app.Run([]string{"inspect", "c@test", "--probe", "--token", "t", "--base-url", srv.URL(), "--history", filepath.Join(t.TempDir(), "h.json")}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var items []struct {
+		File  map[string]any     `json:"file"`
+		Probe *model.ProbeResult `json:"probe"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
+		t.Fatalf("inspect output is not JSON: %v\n%s", err, stdout.String())
+	}
+	if len(items) != 1 || items[0].File["name"] != "c-401" || items[0].Probe == nil || items[0].Probe.Verdict != model.VerdictUnauthorized {
+		t.Fatalf("unexpected inspect output: %s", stdout.String())
+	}
+
+	code = app.Run([]string{"inspect", "nobody", "--token", "t", "--base-url", srv.URL()}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "nobody") {
+		t.Fatalf("expected not-found error, code=%d stderr=%s", code, stderr.String())
+	}
+}
+
+func TestAppFlowSubcommandConfig(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"config", "--token", "secret", "--base-url", "http://127.0.0.1:1", "--max-delete", "5"}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	s := stdout.String()
+	if strings.Contains(s, "secret") || !strings.Contains(s, `"token": "<redacted>"`) || !strings.Contains(s, `"max_delete": 5`) {
+		t.Fatalf("unexpected config output:\n%s", s)
+	}
+
+	code = app.Run([]string{"config", "--strategy", "bogus"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError {
+		t.Fatalf("config should validate options, code=%d", code)
+	}
+}
+
+func TestAppFlowDaemonRequiresCron(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"daemon", "--token", "t", "--base-url", "http://127.0.0.1:1"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "--cron") {
+		t.Fatalf("expected missing cron error, code=%d stderr=%s", code, stderr.String())
+	}
+
+	t.Setenv("CLEAN_CODEX_API_TOKEN", "")
+	stderr.Reset()
+	code = app.Run([]string{"daemon", "--token", "t", "--base-url", "http://127.0.0.1:1", "--cron", "@daily", "--listen", "127.0.0.1:0"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError || !strings.Contains(stderr.String(), "--api-token") {
+		t.Fatalf("expected missing api token error, code=%d stderr=%s", code, stderr.String())
+	}
+}
+
+func TestAppFlowListFormatsAndGroups(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"list", "--token", "t", "--base-url", srv.URL(), "--format", "json", "--columns", "name,email,typo"}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
+		t.Fatalf("list json: %v\n%s", err, stdout.String())
+	}
+	if len(items) != 3 || items[1]["name"] != "b-200" || items[1]["account"] != "b@test" || items[1]["type"] != "codex" {
+		t.Fatalf("unexpected list json: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	code = app.Run([]string{"list", "--token", "t", "--base-url", srv.URL(), "--format", "csv", "--group-by", "provider"}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if stdout.String() != "provider,count\nopenai,3\n" {
+		t.Fatalf("unexpected grouped csv: %q", stdout.String())
+	}
+
+	code = app.Run([]string{"list", "--token", "t", "--base-url", srv.URL(), "--format", "xml"}, strings.NewReader(""), &bytes.Buffer{}, stderr)
+	if code != app.ExitError {
+		t.Fatalf("expected invalid format error, code=%d", code)
+	}
+	if got := srv.probedIndexes(); len(got) != 0 {
+		t.Fatalf("list must not probe, got %v", got)
+	}
+}
+
+func TestAppFlowUsageCapacity(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	srv.setBody("idx-b", `{"plan_type":"plus","rate_limit":{"primary_window":{"used_percent":30,"limit_window_seconds":18000,"reset_after_seconds":60}}}`)
+
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "report.json")
+	outFile := filepath.Join(dir, "invalid.csv")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := app.Run([]string{"check", "--token", "t", "--base-url", srv.URL(), "--output", outFile, "--output-format", "csv", "--report", reportFile}, strings.NewReader(""), stdout, stderr)
+	if code != app.ExitOK {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "额度概况: 健康账号 1 个（1 个有额度信息），平均已用 30.0%") {
+		t.Fatalf("missing capacity summary:\n%s", stdout.String())
+	}
+
+	var rep struct {
+		Capacity *model.Capacity     `json:"capacity"`
+		Accounts []model.ProbeResult `json:"accounts"`
+	}
+	b, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Capacity == nil || rep.Capacity.Healthy != 1 || rep.Capacity.Plans["plus"] != 1 || rep.Capacity.Headroom != 0.7 {
+		t.Fatalf("unexpected capacity: %+v", rep.Capacity)
+	}
+	for _, a := range rep.Accounts {
+		if a.Name == "b-200" && (a.Usage == nil || a.Usage.Primary == nil || a.Usage.Primary.ResetAt == nil) {
+			t.Fatalf("usage not exported for healthy account: %+v", a)
+		}
+	}
+
+	csvOut, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(csvOut), "plan_type,used_percent,reset_at") {
+		t.Fatalf("csv should carry usage columns:\n%s", csvOut)
+	}
+}
+
+func TestAppFlowQuotaCooling(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	exhausted := func(reset time.Time) string {
+		return fmt.Sprintf(`{"usage":{"limit":0},"rate_limit":{"primary_window":{"used_percent":100,"reset_at":%d}}}`, reset.Unix())
+	}
+	countB := func() int {
+		n := 0
+		for _, idx := range srv.probedIndexes() {
+			if idx == "idx-b" {
+				n++
+			}
+		}
+		return n
+	}
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "invalid.json")
+	run := func(historyFile string, extra ...string) []map[string]any {
+		t.Helper()
+		args := append([]string{"check", "--token", "t", "--base-url", srv.URL(), "--output", outFile, "--history", filepath.Join(dir, historyFile)}, extra...)
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		if code := app.Run(args, strings.NewReader(""), stdout, stderr); code != app.ExitOK {
+			t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+		}
+		return readOutputRows(t, outFile)
+	}
+
+	// 重置时间未到：首轮冷却不导出，下一轮不再探测
+	srv.setBody("idx-b", exhausted(time.Now().Add(time.Hour)))
+	if rows := run("future.json"); len(rows) != 2 {
+		t.Fatalf("cooling account should not be exported, got %+v", rows)
+	}
+	if rows := run("future.json"); len(rows) != 2 {
+		t.Fatalf("cooling account should not be exported, got %+v", rows)
+	}
+	if n := countB(); n != 1 {
+		t.Fatalf("cooling account should be skipped until reset, probed %d times", n)
+	}
+
+	// 响应中的重置时间已过（上游数据过期）：按默认时长冷却，紧接着的几轮不复探、不计重置
+	srv.setBody("idx-b", exhausted(time.Now().Add(-time.Minute)))
+	probed := countB()
+	for i := 0; i < 3; i++ {
+		if rows := run("past.json", "--cooling-cycles", "1"); len(rows) != 2 {
+			t.Fatalf("run %d: stale reset_at must not burn cooling cycles, got %+v", i+1, rows)
+		}
+	}
+	if n := countB() - probed; n != 1 {
+		t.Fatalf("stale reset_at should still cool until the default period, probed %d times", n)
+	}
+	historyFile := filepath.Join(dir, "past.json")
+	recs := readHistoryRecords(t, historyFile)
+	until, _ := time.Parse(time.RFC3339Nano, recs[1]["cooling_until"].(string))
+	if recs[1]["key"] != "idx-b" || until.Before(time.Now().Add(23*time.Hour)) || recs[1]["cooling_resets"] != nil {
+		t.Fatalf("unexpected cooling record: %+v", recs[1])
+	}
+
+	// 冷却到期后复探仍耗尽：经历 --cooling-cycles 次重置后按 policy 处置
+	recs[1]["cooling_set_at"] = time.Now().Add(-25 * time.Hour).Format(time.RFC3339Nano)
+	recs[1]["cooling_until"] = time.Now().Add(-time.Minute).Format(time.RFC3339Nano)
+	b, _ := json.Marshal(map[string]any{"version": 1, "records": recs})
+	if err := os.WriteFile(historyFile, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rows := run("past.json", "--cooling-cycles", "1")
+	if len(rows) != 3 || rows[1]["name"] != "b-200" || rows[1]["action"] != "delete" {
+		t.Fatalf("account exhausted after reset should be deleted, got %+v", rows)
+	}
+	if reason, _ := rows[1]["reason"].(string); !strings.HasPrefix(reason, "额度重置 1 次后仍耗尽") {
+		t.Fatalf("unexpected reason: %q", reason)
+	}
+}
+
+// lockedBuffer 供后台运行的 daemon 与测试并发读写日志
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAppFlowDaemonControlAPI(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	stop, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout := &lockedBuffer{}
+	stderr := &lockedBuffer{}
+	exited := make(chan int, 1)
+	go func() {
+		exited <- app.RunContext(stop, []string{
+			"daemon",
+			"--token", "mgmt-secret",
+			"--base-url", srv.URL(),
+			"--output", filepath.Join(dir, "invalid.json"),
+			"--cron", "0 0 1 1 *",
+			"--listen", "127.0.0.1:0",
+			"--api-token", "api-secret",
+			"--no-backup",
+		}, strings.NewReader(""), stdout, stderr)
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s\nstdout:\n%s\nstderr:\n%s", what, stdout.String(), stderr.String())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	var base string
+	waitFor("control listener", func() bool {
+		out := stdout.String()
+		i := strings.Index(out, "控制接口: http://")
+		if i < 0 {
+			return false
+		}
+		base = strings.Fields(out[i+len("控制接口: "):])[0]
+		return true
+	})
+	call := func(method, path, token string, v any) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, strings.TrimSuffix(base, "/api/v1/")+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			_ = json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	if !strings.Contains(stdout.String()+stderr.String(), "未配置任何删除安全阈值") {
+		t.Fatalf("daemon without any guard should warn at start\nstdout:\n%s\nstderr:\n%s", stdout.String(), stderr.String())
+	}
+	if code := call("GET", "/api/v1/status", "mgmt-secret", nil); code != http.StatusUnauthorized {
+		t.Fatalf("management token must not authorize the control API, got %d", code)
+	}
+	if code := call("GET", "/api/v1/report", "api-secret", nil); code != http.StatusNotFound {
+		t.Fatalf("no report before first run, got %d", code)
+	}
+
+	// 暂停删除后手动执行：只探测不删除
+	if code := call("POST", "/api/v1/deletion/pause", "api-secret", nil); code != http.StatusOK {
+		t.Fatalf("pause: %d", code)
+	}
+	if code := call("POST", "/api/v1/run", "api-secret", nil); code != http.StatusAccepted {
+		t.Fatalf("trigger: %d", code)
+	}
+	var reports []map[string]any
+	waitFor("paused run report", func() bool {
+		return call("GET", "/api/v1/report", "api-secret", &reports) == http.StatusOK && len(reports) == 1
+	})
+	if accounts, _ := reports[0]["accounts"].([]any); len(accounts) != 3 {
+		t.Fatalf("report should contain all probed accounts: %+v", reports[0])
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("paused daemon must not delete, got %+v", d)
+	}
+
+	// 恢复删除后再次执行
+	var status map[string]any
+	if code := call("POST", "/api/v1/deletion/resume", "api-secret", &status); code != http.StatusOK || status["delete_paused"] != false {
+		t.Fatalf("resume: %d %+v", code, status)
+	}
+	waitFor("second trigger", func() bool { return call("POST", "/api/v1/run", "api-secret", nil) == http.StatusAccepted })
+	waitFor("deletion", func() bool { return len(srv.deleteNames()) == 2 })
+
+	var accounts []map[string]any
+	waitFor("history", func() bool {
+		accounts = nil
+		return call("GET", "/api/v1/accounts/a-401", "api-secret", &accounts) == http.StatusOK &&
+			len(accounts) == 1 && accounts[0]["history"] != nil &&
+			accounts[0]["history"].(map[string]any)["consecutive_failures"] == float64(2)
+	})
+	accounts = nil
+	if code := call("POST", "/api/v1/accounts/b@test/probe", "api-secret", &accounts); code != http.StatusOK || len(accounts) != 1 {
+		t.Fatalf("probe: %d %+v", code, accounts)
+	}
+	if p, _ := accounts[0]["probe"].(map[string]any); p["verdict"] != "healthy" {
+		t.Fatalf("unexpected probe result: %+v", accounts[0])
+	}
+	if code := call("GET", "/api/v1/accounts/nobody", "api-secret", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown account should be 404, got %d", code)
+	}
+
+	var conf struct {
+		Targets []struct {
+			Options map[string]any `json:"options"`
+		} `json:"targets"`
+	}
+	if code := call("GET", "/api/v1/config", "api-secret", &conf); code != http.StatusOK || len(conf.Targets) != 1 || conf.Targets[0].Options["token"] != "<redacted>" {
+		t.Fatalf("config: %d %+v", code, conf)
+	}
+
+	cancel()
+	select {
+	case code := <-exited:
+		if code != app.ExitOK {
+			t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not exit")
+	}
+}
+
+func TestAppFlowRefusesAccountsWithoutProbeDefinition(t *testing.T) {
+	srv := newMockServer(t)
+	defer srv.Close()
+	srv.addFile(map[string]any{"name": "d-claude", "account": "d@test", "auth_index": "idx-d", "type": "claude", "provider": "anthropic"})
+
+	dir := t.TempDir()
+	historyFile := filepath.Join(dir, "history.json")
+	args := []string{"delete", "--token", "t", "--base-url", srv.URL(), "--output", filepath.Join(dir, "invalid.json"), "--history", historyFile, "--target-type", "", "--yes", "--no-backup"}
+	// 超过连续异常升级阈值（10 次）也不能处置无法探测的账号
+	for i := 0; i < 12; i++ {
+		stderr := &bytes.Buffer{}
+		code := app.Run(args, strings.NewReader(""), &bytes.Buffer{}, stderr)
+		if code != app.ExitError || !strings.Contains(stderr.String(), "type=claude provider=anthropic（1 个）") {
+			t.Fatalf("run %d: expected refusal, code=%d stderr=%s", i+1, code, stderr.String())
+		}
+	}
+	if d := srv.deleteNames(); len(d) != 0 {
+		t.Fatalf("nothing should be deleted, got %+v", d)
+	}
+	if p := srv.probedIndexes(); len(p) != 0 {
+		t.Fatalf("nothing should be probed, got %+v", p)
+	}
+	if _, err := os.Stat(historyFile); !os.IsNotExist(err) {
+		t.Fatalf("history must not be written: %v", err)
+	}
+
+	// 缩小范围后正常处置
+	args[10] = "codex"
+	if code := app.Run(args, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); code != app.ExitOK {
+		t.Fatalf("codex-only run failed: %d", code)
+	}
+	if d := srv.deleteNames(); len(d) != 2 {
+		t.Fatalf("expected the two 401 accounts to be deleted, got %+v", d)
 	}
 }